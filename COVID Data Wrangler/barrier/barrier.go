@@ -0,0 +1,66 @@
+/*
+Package barrier provides barriers that hold back a fixed number of workers until all of them
+have arrived, such as the workers of a BSP superstep. Every barrier implements Barrier, so
+they can be swapped for each other to measure which one suits a thread count best. A barrier
+can be reused for any number of rounds, and every worker must pass its own ID, from 0 to the
+number of workers - 1, from a single goroutine.
+*/
+package barrier
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync/atomic"
+)
+
+// Barrier synchronizes a fixed number of workers, round after round
+type Barrier interface {
+	// Wait blocks worker id until every worker has called Wait for the same round
+	Wait(id int)
+}
+
+// Barriers are the available barrier constructors by name
+var Barriers = map[string]func(numWorkers int) Barrier{
+	"central":       NewCentral,
+	"sense":         NewSenseReversing,
+	"dissemination": NewDissemination,
+	"tournament":    NewTournament,
+}
+
+// Names lists the names in Barriers, sorted
+func Names() []string {
+	names := []string{}
+	for name := range Barriers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the constructor registered under name
+func Lookup(name string) (func(numWorkers int) Barrier, error) {
+	newBarrier, ok := Barriers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown barrier %q, want one of %v", name, Names())
+	}
+	return newBarrier, nil
+}
+
+// flag is a flag a worker spins on, padded to a cache line of its own so that setting the
+// flags of other workers does not invalidate it
+type flag struct {
+	value int32
+	_     [60]byte
+}
+
+func (flag *flag) set(value int32) {
+	atomic.StoreInt32(&flag.value, value)
+}
+
+// await spins until the flag holds value, yielding so that the workers being waited for can run
+func (flag *flag) await(value int32) {
+	for atomic.LoadInt32(&flag.value) != value {
+		runtime.Gosched()
+	}
+}
@@ -0,0 +1,87 @@
+package barrier
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitOrFail waits for the workers, failing the test if a broken barrier left some of them waiting for good
+func waitOrFail(t *testing.T, group *sync.WaitGroup, name string, numWorkers int) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		group.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("%v, %v workers: deadlocked", name, numWorkers)
+	}
+}
+
+/*
+Every worker records its arrival at round r before waiting, and checks after the barrier that
+every worker has arrived at round r. The arrivals are counted per round, so a worker released
+early would see fewer than all of them, whatever the others do next.
+*/
+func TestNoWorkerLeavesBeforeAllArrive(t *testing.T) {
+	const rounds = 500
+	for name, newBarrier := range Barriers {
+		for _, numWorkers := range []int{1, 2, 3, 5, 8, 13} {
+			barrier := newBarrier(numWorkers)
+			arrived := make([]int32, rounds)
+			var group sync.WaitGroup
+			for id := 0; id < numWorkers; id++ {
+				group.Add(1)
+				go func(id int) {
+					defer group.Done()
+					failed := false
+					for round := 0; round < rounds; round++ {
+						atomic.AddInt32(&arrived[round], 1)
+						barrier.Wait(id)
+						// keep going after a failure, so that the others are not left waiting at the barrier
+						if count := atomic.LoadInt32(&arrived[round]); count != int32(numWorkers) && !failed {
+							t.Errorf("%v, %v workers: worker %v left round %v with %v arrived", name, numWorkers, id, round, count)
+							failed = true
+						}
+					}
+				}(id)
+			}
+			waitOrFail(t, &group, name, numWorkers)
+		}
+	}
+}
+
+// Data written before the barrier by one worker must be visible to all the others after it, with no data race
+func TestBarrierOrdersMemory(t *testing.T) {
+	const rounds = 200
+	for name, newBarrier := range Barriers {
+		for _, numWorkers := range []int{2, 5, 8} {
+			barrier := newBarrier(numWorkers)
+			slots := make([]int, numWorkers) // plain ints, read by everyone between the barriers
+			var group sync.WaitGroup
+			for id := 0; id < numWorkers; id++ {
+				group.Add(1)
+				go func(id int) {
+					defer group.Done()
+					failed := false
+					for round := 1; round <= rounds; round++ {
+						slots[id] = round
+						barrier.Wait(id)
+						for other, value := range slots {
+							if value != round && !failed {
+								t.Errorf("%v, %v workers: worker %v saw %v for worker %v in round %v", name, numWorkers, id, value, other, round)
+								failed = true
+							}
+						}
+						barrier.Wait(id)
+					}
+				}(id)
+			}
+			waitOrFail(t, &group, name, numWorkers)
+		}
+	}
+}
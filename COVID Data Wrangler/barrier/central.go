@@ -0,0 +1,39 @@
+package barrier
+
+import "sync"
+
+/*
+Central is a central counter barrier behind a mutex: every worker counts itself in and sleeps
+on a condition variable, and the last one to arrive starts the next round and wakes the others.
+Waiting workers are parked instead of spinning, so it suits workers that wait long, but waking
+them up goes through the scheduler.
+*/
+type Central struct {
+	mutex      sync.Mutex
+	cond       *sync.Cond
+	numWorkers int
+	arrived    int
+	round      int // rounds completed so far
+}
+
+func NewCentral(numWorkers int) Barrier {
+	barrier := &Central{numWorkers: numWorkers}
+	barrier.cond = sync.NewCond(&barrier.mutex)
+	return barrier
+}
+
+func (barrier *Central) Wait(id int) {
+	barrier.mutex.Lock()
+	defer barrier.mutex.Unlock()
+	round := barrier.round
+	barrier.arrived++
+	if barrier.arrived == barrier.numWorkers {
+		barrier.arrived = 0
+		barrier.round++
+		barrier.cond.Broadcast()
+		return
+	}
+	for round == barrier.round {
+		barrier.cond.Wait()
+	}
+}
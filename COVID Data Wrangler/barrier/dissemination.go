@@ -0,0 +1,50 @@
+package barrier
+
+/*
+Dissemination is a barrier without any shared counter: in round r, worker i signals worker
+i + 2^r (modulo the number of workers) and waits for the signal of worker i - 2^r. After
+ceil(log2 P) rounds every worker has heard, directly or indirectly, from every other one. Each
+worker spins on flags of its own only, and the rounds are not serialized through one worker.
+
+The flags are used alternately by two sets, by the parity of the barrier's round, and the value
+signalled flips every other round, so a flag never needs to be reset.
+*/
+type Dissemination struct {
+	numWorkers int
+	rounds     int
+	flags      [][2][]flag // flags[i][parity][r] is set by the partner signalling worker i in round r
+	parity     []flag      // only touched by the worker
+	sense      []flag      // only touched by the worker
+}
+
+func NewDissemination(numWorkers int) Barrier {
+	barrier := &Dissemination{numWorkers: numWorkers}
+	for distance := 1; distance < numWorkers; distance *= 2 {
+		barrier.rounds++
+	}
+	barrier.flags = make([][2][]flag, numWorkers)
+	for i := range barrier.flags {
+		for parity := range barrier.flags[i] {
+			barrier.flags[i][parity] = make([]flag, barrier.rounds)
+		}
+	}
+	barrier.parity = make([]flag, numWorkers)
+	barrier.sense = make([]flag, numWorkers)
+	for i := range barrier.sense {
+		barrier.sense[i].value = 1
+	}
+	return barrier
+}
+
+func (barrier *Dissemination) Wait(id int) {
+	parity, sense := barrier.parity[id].value, barrier.sense[id].value
+	for r, distance := 0, 1; r < barrier.rounds; r, distance = r+1, distance*2 {
+		partner := (id + distance) % barrier.numWorkers
+		barrier.flags[partner][parity][r].set(sense)
+		barrier.flags[id][parity][r].await(sense)
+	}
+	if parity == 1 {
+		barrier.sense[id].value = 1 - sense
+	}
+	barrier.parity[id].value = 1 - parity
+}
@@ -0,0 +1,32 @@
+package barrier
+
+import "sync/atomic"
+
+/*
+SenseReversing is a central counter barrier without a lock: every worker decrements an atomic
+counter and spins on a shared sense flag. The last one to arrive resets the counter and flips
+the sense, releasing the others. The sense alternates between rounds, and every worker keeps
+the sense of its current round, so a fast worker entering the next round cannot be confused
+with a slow one still leaving the last.
+*/
+type SenseReversing struct {
+	numWorkers int32
+	count      int32
+	sense      flag
+	local      []flag // the sense of every worker's current round, only touched by the worker
+}
+
+func NewSenseReversing(numWorkers int) Barrier {
+	return &SenseReversing{numWorkers: int32(numWorkers), count: int32(numWorkers), local: make([]flag, numWorkers)}
+}
+
+func (barrier *SenseReversing) Wait(id int) {
+	sense := 1 - barrier.local[id].value
+	barrier.local[id].value = sense
+	if atomic.AddInt32(&barrier.count, -1) == 0 {
+		atomic.StoreInt32(&barrier.count, barrier.numWorkers)
+		barrier.sense.set(sense)
+		return
+	}
+	barrier.sense.await(sense)
+}
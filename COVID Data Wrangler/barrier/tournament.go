@@ -0,0 +1,60 @@
+package barrier
+
+/*
+Tournament is a barrier played as a knockout tournament with fixed winners. In round r, worker
+i with i a multiple of 2^(r+1) waits for worker i + 2^r to arrive, and the latter, having lost,
+waits to be woken up. Worker 0 wins the last round once everyone has arrived, and wakes up the
+workers it beat, which wake up the ones they beat in turn, so the release spreads down the
+tree in ceil(log2 P) steps. Every worker spins on flags of its own only.
+
+The value signalled flips every round of the barrier, so a flag never needs to be reset.
+*/
+type Tournament struct {
+	numWorkers int
+	arrived    [][]flag // arrived[i][r] is set by the worker that loses to worker i in round r
+	wakeup     []flag   // set by the worker that beat the worker
+	sense      []flag   // only touched by the worker
+}
+
+func NewTournament(numWorkers int) Barrier {
+	rounds := 0
+	for distance := 1; distance < numWorkers; distance *= 2 {
+		rounds++
+	}
+	barrier := &Tournament{numWorkers: numWorkers}
+	barrier.arrived = make([][]flag, numWorkers)
+	for i := range barrier.arrived {
+		barrier.arrived[i] = make([]flag, rounds)
+	}
+	barrier.wakeup = make([]flag, numWorkers)
+	barrier.sense = make([]flag, numWorkers)
+	for i := range barrier.sense {
+		barrier.sense[i].value = 1
+	}
+	return barrier
+}
+
+func (barrier *Tournament) Wait(id int) {
+	sense := barrier.sense[id].value
+	barrier.sense[id].value = 1 - sense
+
+	// play rounds until losing one, or winning the tournament
+	r, distance := 0, 1
+	for ; distance < barrier.numWorkers; r, distance = r+1, distance*2 {
+		if id%(2*distance) != 0 {
+			barrier.arrived[id-distance][r].set(sense)
+			barrier.wakeup[id].await(sense)
+			break
+		}
+		if id+distance < barrier.numWorkers {
+			barrier.arrived[id][r].await(sense)
+		}
+	}
+
+	// wake up the workers beaten in the rounds before, the latest first
+	for r, distance = r-1, distance/2; r >= 0; r, distance = r-1, distance/2 {
+		if id+distance < barrier.numWorkers {
+			barrier.wakeup[id+distance].set(sense)
+		}
+	}
+}
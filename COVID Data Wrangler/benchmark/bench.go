@@ -0,0 +1,156 @@
+package benchmark
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"proj3/modes"
+	"proj3/utils"
+	"runtime"
+	"time"
+)
+
+const usage = "Usage:	go run proj3/covid bench [flags]\n" +
+	"	runs every selected mode, size and thread count in-process and reports the timings\n" +
+	"	flags:\n"
+
+/*
+measure runs one configuration warmup times without timing it, then reps times timed.
+A garbage collection is forced before every timed repetition so that garbage left over
+by the previous run is not billed to this one.
+*/
+func measure(mode modes.Mode, args *utils.Arguments, size int, threads int, opts *modes.Options, warmup int, reps int) []time.Duration {
+	for i := 0; i < warmup; i++ {
+		mode.Run(args, size, threads, opts)
+	}
+	samples := make([]time.Duration, reps)
+	for i := 0; i < reps; i++ {
+		runtime.GC()
+		start := time.Now()
+		mode.Run(args, size, threads, opts)
+		samples[i] = time.Since(start)
+	}
+	return samples
+}
+
+/*
+Main runs the bench command with the arguments following "bench" on the command line and
+returns the process exit code.
+
+For each size the sequential mode is measured first and used as the baseline for the
+speedup of every parallel mode and thread count at that size. Thread counts below a
+mode's minimum are skipped.
+*/
+func Main(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	modeList := fs.String("modes", "", "comma separated modes to benchmark (default: every registered parallel mode)")
+	sizeList := fs.String("sizes", "500,1000,3000", "comma separated numbers of files to process")
+	threadList := fs.String("threads", "2,4,6,8,12", "comma separated thread counts")
+	reps := fs.Int("reps", 5, "timed repetitions per configuration")
+	warmup := fs.Int("warmup", 1, "untimed warm-up runs per configuration")
+	zipcode := fs.String("zipcode", "60603", "zipcode to query")
+	month := fs.Int("month", 5, "month to query")
+	year := fs.Int("year", 2020, "year to query")
+	csvPath := fs.String("csv", "", "write the results as CSV to this file")
+	jsonPath := fs.String("json", "", "write the results, including every sample, as JSON to this file")
+	svgDir := fs.String("svg", "", "write speedup, efficiency and Karp–Flatt charts as SVG files into this directory")
+	opts := &modes.Options{}
+	opts.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	positional, err := utils.ParseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(positional) != 0 || *reps < 1 || *warmup < 0 {
+		fs.Usage()
+		return 2
+	}
+	sizes, err := utils.ParseIntList(*sizeList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	threads, err := utils.ParseIntList(*threadList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	selected := []modes.Mode{}
+	if *modeList == "" {
+		for _, mode := range modes.Modes() {
+			if mode.Name != "sequential" {
+				selected = append(selected, mode)
+			}
+		}
+	} else {
+		for _, name := range utils.SplitList(*modeList) {
+			mode, ok := modes.Lookup(name)
+			if !ok || mode.Name == "sequential" {
+				fmt.Fprintf(os.Stderr, "unknown parallel mode %q\n", name)
+				return 2
+			}
+			selected = append(selected, mode)
+		}
+	}
+	sequential, _ := modes.Lookup("sequential")
+
+	arguments := utils.Arguments{Zipcode: *zipcode, Month: *month, Year: *year}
+	report := &Report{Zipcode: *zipcode, Month: *month, Year: *year, Reps: *reps, Warmup: *warmup,
+		GoMaxProcs: runtime.GOMAXPROCS(0), NumCPU: runtime.NumCPU(), Options: *opts}
+
+	fmt.Printf("%-12v %6v %7v %10v %10v %10v %8v\n", "mode", "size", "threads", "median(s)", "mean(s)", "stddev(s)", "speedup")
+	for _, size := range sizes {
+		baseline := summarize(sequential.Name, size, 1, measure(sequential, &arguments, size, 1, opts, *warmup, *reps))
+		baseline.Speedup = 1
+		report.Results = append(report.Results, baseline)
+		printSummary(baseline)
+
+		for _, mode := range selected {
+			for _, numThreads := range threads {
+				if numThreads < mode.MinThreads {
+					continue
+				}
+				summary := summarize(mode.Name, size, numThreads, measure(mode, &arguments, size, numThreads, opts, *warmup, *reps))
+				if summary.Median > 0 {
+					summary.Speedup = baseline.Median / summary.Median
+				}
+				report.Results = append(report.Results, summary)
+				printSummary(summary)
+			}
+		}
+	}
+
+	if *csvPath != "" {
+		if err := writeCSV(*csvPath, report); err != nil {
+			fmt.Fprintf(os.Stderr, "writing %v: %v\n", *csvPath, err)
+			return 1
+		}
+	}
+	if *jsonPath != "" {
+		if err := writeJSON(*jsonPath, report); err != nil {
+			fmt.Fprintf(os.Stderr, "writing %v: %v\n", *jsonPath, err)
+			return 1
+		}
+	}
+	if *svgDir != "" {
+		if err := writeCharts(*svgDir, report); err != nil {
+			fmt.Fprintf(os.Stderr, "writing charts to %v: %v\n", *svgDir, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+func printSummary(summary Summary) {
+	fmt.Printf("%-12v %6v %7v %10.4f %10.4f %10.4f %8.2f\n", summary.Mode, summary.Size, summary.Threads,
+		summary.Median, summary.Mean, summary.Stddev, summary.Speedup)
+}
@@ -1,17 +1,24 @@
 #!/bin/bash
 #
-#SBATCH --mail-user=adamzhang22@cs.uchicago.edu
-#SBATCH --mail-type=ALL
-#SBATCH --job-name=proj3_benchmark 
-#SBATCH --output=./slurm/out/%j.%N.stdout
-#SBATCH --error=./slurm/out/%j.%N.stderr
-#SBATCH --chdir=/home/adamzhang22/mpcs52060/proj3-adamzhang822/proj3/benchmark
-#SBATCH --partition=debug
+# Runs the in-process benchmark and writes the results and SVG charts next to this script.
+# Any arguments are passed on to the bench command, e.g.
+#   ./benchmark-proj3.sh -sizes 500,1000 -threads 2,4,8 -reps 10
+#
+# The script can also be submitted to SLURM as is from this directory (sbatch copies the
+# script elsewhere, so under SLURM the submit directory stands in for the script location).
+#
+#SBATCH --job-name=proj3_benchmark
 #SBATCH --nodes=1
 #SBATCH --ntasks=1
 #SBATCH --cpus-per-task=16
 #SBATCH --mem-per-cpu=300
 #SBATCH --time=200:00
 
-module load golang/1.16.2 
-python3 graph.py
+set -euo pipefail
+
+BENCH_DIR="${SLURM_SUBMIT_DIR:-$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)}"
+
+# data is read relative to the covid directory, so the bench must run from there
+cd "$BENCH_DIR/../covid"
+go build -o "$BENCH_DIR/covid-bench" proj3/covid
+"$BENCH_DIR/covid-bench" bench -csv "$BENCH_DIR/results.csv" -json "$BENCH_DIR/results.json" -svg "$BENCH_DIR/charts" "$@"
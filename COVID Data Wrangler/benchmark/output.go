@@ -0,0 +1,81 @@
+package benchmark
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"proj3/modes"
+	"strconv"
+)
+
+// Report is everything a benchmark run produced, as written to the JSON output
+type Report struct {
+	Zipcode    string        `json:"zipcode"`
+	Month      int           `json:"month"`
+	Year       int           `json:"year"`
+	Reps       int           `json:"reps"`
+	Warmup     int           `json:"warmup"`
+	GoMaxProcs int           `json:"gomaxprocs"`
+	NumCPU     int           `json:"numcpu"`
+	Options    modes.Options `json:"options"` // the tuning flags every mode ran with
+	Results    []Summary     `json:"results"`
+}
+
+// writeCSV writes one row per summary to path, returning the first error writing or closing the file
+func writeCSV(path string, report *Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	return closeAfter(file, func() error {
+		writer := csv.NewWriter(file)
+		header := []string{"mode", "size", "threads", "reps", "median_s", "mean_s", "stddev_s", "min_s", "max_s", "speedup"}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		for _, summary := range report.Results {
+			err := writer.Write([]string{
+				summary.Mode,
+				strconv.Itoa(summary.Size),
+				strconv.Itoa(summary.Threads),
+				strconv.Itoa(len(summary.Samples)),
+				formatFloat(summary.Median),
+				formatFloat(summary.Mean),
+				formatFloat(summary.Stddev),
+				formatFloat(summary.Min),
+				formatFloat(summary.Max),
+				formatFloat(summary.Speedup),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	})
+}
+
+func writeJSON(path string, report *Report) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	return closeAfter(file, func() error {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	})
+}
+
+// closeAfter runs write and closes file, returning the error of write or else that of closing, which may lose buffered data
+func closeAfter(file *os.File, write func() error) error {
+	err := write()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', 6, 64)
+}
@@ -0,0 +1,42 @@
+package benchmark
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteCSV(t *testing.T) {
+	report := &Report{Results: []Summary{{Mode: "static", Size: 500, Threads: 4, Samples: []float64{1, 2}, Median: 1.5, Speedup: 2}}}
+	path := filepath.Join(t.TempDir(), "results.csv")
+	if err := writeCSV(path, report); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1][0] != "static" || rows[1][3] != "2" || rows[1][4] != "1.500000" {
+		t.Errorf("rows = %v", rows)
+	}
+}
+
+// The rows are buffered, so a full disk only shows when they are flushed, and must not be lost
+func TestWriteOutputReportsFullDisk(t *testing.T) {
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full")
+	}
+	report := &Report{Results: []Summary{{Mode: "static"}}}
+	if err := writeCSV("/dev/full", report); err == nil {
+		t.Error("writeCSV to a full disk returned no error")
+	}
+	if err := writeJSON("/dev/full", report); err == nil {
+		t.Error("writeJSON to a full disk returned no error")
+	}
+}
@@ -0,0 +1,58 @@
+package benchmark
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Summary holds the statistics of the timed repetitions of one configuration, in seconds
+type Summary struct {
+	Mode    string    `json:"mode"`
+	Size    int       `json:"size"`
+	Threads int       `json:"threads"`
+	Samples []float64 `json:"samples"`
+	Median  float64   `json:"median"`
+	Mean    float64   `json:"mean"`
+	Stddev  float64   `json:"stddev"`
+	Min     float64   `json:"min"`
+	Max     float64   `json:"max"`
+	Speedup float64   `json:"speedup"` // sequential median / median, 1 for sequential itself
+}
+
+func summarize(mode string, size int, threads int, samples []time.Duration) Summary {
+	summary := Summary{Mode: mode, Size: size, Threads: threads, Samples: make([]float64, len(samples))}
+	for i, sample := range samples {
+		summary.Samples[i] = sample.Seconds()
+	}
+	if len(samples) == 0 {
+		return summary
+	}
+
+	sorted := make([]float64, len(summary.Samples))
+	copy(sorted, summary.Samples)
+	sort.Float64s(sorted)
+	summary.Min = sorted[0]
+	summary.Max = sorted[len(sorted)-1]
+	if len(sorted)%2 == 1 {
+		summary.Median = sorted[len(sorted)/2]
+	} else {
+		summary.Median = (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+	}
+
+	total := 0.0
+	for _, sample := range sorted {
+		total += sample
+	}
+	summary.Mean = total / float64(len(sorted))
+
+	// sample standard deviation, 0 when there is a single repetition
+	if len(sorted) > 1 {
+		squares := 0.0
+		for _, sample := range sorted {
+			squares += (sample - summary.Mean) * (sample - summary.Mean)
+		}
+		summary.Stddev = math.Sqrt(squares / float64(len(sorted)-1))
+	}
+	return summary
+}
@@ -0,0 +1,224 @@
+package benchmark
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// chart dimensions and margins in pixels
+const (
+	chartWidth   = 720
+	chartHeight  = 440
+	marginLeft   = 70
+	marginRight  = 150
+	marginTop    = 50
+	marginBottom = 60
+)
+
+var palette = []string{"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f"}
+
+type point struct {
+	X float64
+	Y float64
+}
+
+type series struct {
+	Label  string
+	Points []point
+}
+
+// A metric derives one chart value from a summary, ok is false when it is undefined
+type metric struct {
+	Name   string
+	Title  string
+	YLabel string
+	Value  func(summary Summary) (value float64, ok bool)
+}
+
+/*
+The three charts drawn per mode. Efficiency is speedup per thread, and the Karp–Flatt metric
+is the experimentally determined serial fraction e = (1/S - 1/p) / (1 - 1/p), which is
+undefined for a single thread.
+*/
+var metrics = []metric{
+	{Name: "speedup", Title: "Speedup", YLabel: "Speedup", Value: func(summary Summary) (float64, bool) {
+		return summary.Speedup, true
+	}},
+	{Name: "efficiency", Title: "Parallel efficiency", YLabel: "Efficiency (speedup / threads)", Value: func(summary Summary) (float64, bool) {
+		return summary.Speedup / float64(summary.Threads), true
+	}},
+	{Name: "karpflatt", Title: "Karp–Flatt serial fraction", YLabel: "Serial fraction", Value: func(summary Summary) (float64, bool) {
+		if summary.Threads < 2 || summary.Speedup == 0 {
+			return 0, false
+		}
+		p := float64(summary.Threads)
+		return (1/summary.Speedup - 1/p) / (1 - 1/p), true
+	}},
+}
+
+/*
+writeCharts writes one SVG per metric and parallel mode into dir, named like speedup-static.svg,
+with one series per input size plotted against the thread count.
+*/
+func writeCharts(dir string, report *Report) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// group the results by mode, then by size, keeping the order modes were benchmarked in
+	modeOrder := []string{}
+	bySize := make(map[string]map[int][]Summary)
+	for _, summary := range report.Results {
+		if summary.Mode == "sequential" {
+			continue
+		}
+		if _, contains := bySize[summary.Mode]; !contains {
+			modeOrder = append(modeOrder, summary.Mode)
+			bySize[summary.Mode] = make(map[int][]Summary)
+		}
+		bySize[summary.Mode][summary.Size] = append(bySize[summary.Mode][summary.Size], summary)
+	}
+
+	for _, mode := range modeOrder {
+		sizes := []int{}
+		for size := range bySize[mode] {
+			sizes = append(sizes, size)
+		}
+		sort.Ints(sizes)
+
+		for _, metric := range metrics {
+			allSeries := []series{}
+			for _, size := range sizes {
+				line := series{Label: fmt.Sprintf("size %v", size)}
+				for _, summary := range bySize[mode][size] {
+					if value, ok := metric.Value(summary); ok {
+						line.Points = append(line.Points, point{X: float64(summary.Threads), Y: value})
+					}
+				}
+				sort.Slice(line.Points, func(i, j int) bool { return line.Points[i].X < line.Points[j].X })
+				allSeries = append(allSeries, line)
+			}
+			title := fmt.Sprintf("%v (%v)", metric.Title, mode)
+			svg := renderChart(title, "Number of threads", metric.YLabel, allSeries)
+			path := filepath.Join(dir, fmt.Sprintf("%v-%v.svg", metric.Name, mode))
+			if err := os.WriteFile(path, svg, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// niceTicks returns evenly spaced round tick values covering [low, high]
+func niceTicks(low float64, high float64, count int) []float64 {
+	if high <= low {
+		high = low + 1
+	}
+	rawStep := (high - low) / float64(count)
+	magnitude := math.Pow(10, math.Floor(math.Log10(rawStep)))
+	step := magnitude
+	for _, factor := range []float64{1, 2, 2.5, 5, 10} {
+		step = factor * magnitude
+		if step >= rawStep {
+			break
+		}
+	}
+	ticks := []float64{}
+	for tick := math.Floor(low/step) * step; tick <= high+step/2; tick += step {
+		ticks = append(ticks, tick)
+	}
+	return ticks
+}
+
+func escape(text string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(text))
+	return buf.String()
+}
+
+// renderChart draws a self-contained SVG line chart with axes, grid lines and a legend
+func renderChart(title string, xLabel string, yLabel string, allSeries []series) []byte {
+	// data bounds, the y axis always includes 0 so that charts are comparable by eye
+	minX, maxX := math.Inf(1), math.Inf(-1)
+	minY, maxY := 0.0, math.Inf(-1)
+	for _, line := range allSeries {
+		for _, p := range line.Points {
+			minX = math.Min(minX, p.X)
+			maxX = math.Max(maxX, p.X)
+			minY = math.Min(minY, p.Y)
+			maxY = math.Max(maxY, p.Y)
+		}
+	}
+	if math.IsInf(minX, 1) {
+		minX, maxX, maxY = 0, 1, 1
+	}
+	xTicks := niceTicks(minX, maxX, 6)
+	yTicks := niceTicks(minY, maxY, 6)
+	minX, maxX = math.Min(minX, xTicks[0]), math.Max(maxX, xTicks[len(xTicks)-1])
+	minY, maxY = yTicks[0], yTicks[len(yTicks)-1]
+
+	plotWidth := float64(chartWidth - marginLeft - marginRight)
+	plotHeight := float64(chartHeight - marginTop - marginBottom)
+	scaleX := func(x float64) float64 { return marginLeft + (x-minX)/(maxX-minX)*plotWidth }
+	scaleY := func(y float64) float64 { return marginTop + plotHeight - (y-minY)/(maxY-minY)*plotHeight }
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" viewBox="0 0 %v %v" font-family="sans-serif" font-size="12">`+"\n",
+		chartWidth, chartHeight, chartWidth, chartHeight)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(&buf, `<text x="%v" y="%v" text-anchor="middle" font-size="16">%v</text>`+"\n",
+		marginLeft+plotWidth/2, marginTop/2+5, escape(title))
+
+	// grid lines and tick labels
+	for _, tick := range xTicks {
+		x := scaleX(tick)
+		fmt.Fprintf(&buf, `<line x1="%.1f" y1="%v" x2="%.1f" y2="%.1f" stroke="#e0e0e0"/>`+"\n", x, marginTop, x, marginTop+plotHeight)
+		fmt.Fprintf(&buf, `<text x="%.1f" y="%.1f" text-anchor="middle">%v</text>`+"\n", x, marginTop+plotHeight+18, formatTick(tick))
+	}
+	for _, tick := range yTicks {
+		y := scaleY(tick)
+		fmt.Fprintf(&buf, `<line x1="%v" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#e0e0e0"/>`+"\n", marginLeft, y, marginLeft+plotWidth, y)
+		fmt.Fprintf(&buf, `<text x="%v" y="%.1f" text-anchor="end">%v</text>`+"\n", marginLeft-8, y+4, formatTick(tick))
+	}
+
+	// axes and their labels
+	fmt.Fprintf(&buf, `<rect x="%v" y="%v" width="%.1f" height="%.1f" fill="none" stroke="black"/>`+"\n",
+		marginLeft, marginTop, plotWidth, plotHeight)
+	fmt.Fprintf(&buf, `<text x="%.1f" y="%v" text-anchor="middle">%v</text>`+"\n",
+		marginLeft+plotWidth/2, chartHeight-15, escape(xLabel))
+	fmt.Fprintf(&buf, `<text x="18" y="%.1f" text-anchor="middle" transform="rotate(-90 18 %.1f)">%v</text>`+"\n",
+		marginTop+plotHeight/2, marginTop+plotHeight/2, escape(yLabel))
+
+	// one polyline with markers per series, and its legend entry
+	for i, line := range allSeries {
+		color := palette[i%len(palette)]
+		coords := bytes.Buffer{}
+		for _, p := range line.Points {
+			fmt.Fprintf(&coords, "%.1f,%.1f ", scaleX(p.X), scaleY(p.Y))
+		}
+		fmt.Fprintf(&buf, `<polyline points="%v" fill="none" stroke="%v" stroke-width="2"/>`+"\n", coords.String(), color)
+		for _, p := range line.Points {
+			fmt.Fprintf(&buf, `<circle cx="%.1f" cy="%.1f" r="3" fill="%v"><title>%v: %v threads, %.3f</title></circle>`+"\n",
+				scaleX(p.X), scaleY(p.Y), color, escape(line.Label), p.X, p.Y)
+		}
+		legendY := marginTop + 10 + i*20
+		fmt.Fprintf(&buf, `<line x1="%.1f" y1="%v" x2="%.1f" y2="%v" stroke="%v" stroke-width="2"/>`+"\n",
+			marginLeft+plotWidth+15, legendY, marginLeft+plotWidth+35, legendY, color)
+		fmt.Fprintf(&buf, `<text x="%.1f" y="%v">%v</text>`+"\n", marginLeft+plotWidth+40, legendY+4, escape(line.Label))
+	}
+
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+func formatTick(value float64) string {
+	if value == math.Trunc(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.3g", value)
+}
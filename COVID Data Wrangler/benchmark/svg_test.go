@@ -0,0 +1,62 @@
+package benchmark
+
+import (
+	"encoding/xml"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+// The ticks must cover the range with at least two round, increasing values, even when it is a single point
+func TestNiceTicks(t *testing.T) {
+	for _, test := range []struct{ low, high float64 }{
+		{1, 12},    // threads 1 to 12
+		{0, 0.37},  // efficiencies
+		{8, 8},     // one thread count
+		{0, 0},     // a single point at 0
+		{1.5, 1.5}, // a single speedup
+		{-0.2, 1},  // negative Karp-Flatt fractions
+		{0, 3000},
+	} {
+		ticks := niceTicks(test.low, test.high, 6)
+		if len(ticks) < 2 {
+			t.Errorf("niceTicks(%v, %v) = %v, want at least two", test.low, test.high, ticks)
+			continue
+		}
+		if ticks[0] > test.low || ticks[len(ticks)-1] < test.high {
+			t.Errorf("niceTicks(%v, %v) = %v do not cover the range", test.low, test.high, ticks)
+		}
+		for i := 1; i < len(ticks); i++ {
+			if !(ticks[i] > ticks[i-1]) || math.IsNaN(ticks[i]) || math.IsInf(ticks[i], 0) {
+				t.Errorf("niceTicks(%v, %v) = %v are not increasing", test.low, test.high, ticks)
+				break
+			}
+		}
+	}
+}
+
+// A bench of one thread count or one size still draws a well-formed chart with finite coordinates
+func TestRenderChartSinglePoint(t *testing.T) {
+	for _, allSeries := range [][]series{
+		{{Label: "500 files", Points: []point{{X: 4, Y: 1}}}},
+		{{Label: "500 files", Points: []point{{X: 1, Y: 0}}}},
+		{},
+	} {
+		svg := string(renderChart("Speedup (static)", "Number of threads", "Speedup", allSeries))
+		if strings.Contains(svg, "NaN") || strings.Contains(svg, "Inf") {
+			t.Errorf("chart of %v has non-finite coordinates:\n%v", allSeries, svg)
+		}
+		decoder := xml.NewDecoder(strings.NewReader(svg))
+		for {
+			_, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("chart of %v is not well-formed: %v", allSeries, err)
+				break
+			}
+		}
+	}
+}
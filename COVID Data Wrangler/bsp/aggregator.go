@@ -0,0 +1,37 @@
+package bsp
+
+/*
+Aggregator combines values contributed by the workers during a superstep into one value,
+which every worker can read in the next superstep. Combine must be associative and
+commutative, since the contributions are combined in no particular order.
+*/
+type Aggregator struct {
+	Zero    interface{}
+	Combine func(a interface{}, b interface{}) interface{}
+	// Persistent aggregators keep combining across supersteps, others start from Zero every superstep
+	Persistent bool
+}
+
+// Sum adds up int contributions
+func Sum(persistent bool) Aggregator {
+	return Aggregator{Zero: 0, Persistent: persistent, Combine: func(a interface{}, b interface{}) interface{} {
+		return a.(int) + b.(int)
+	}}
+}
+
+// Max keeps the largest int contribution, for contributions that are not negative
+func Max(persistent bool) Aggregator {
+	return Aggregator{Zero: 0, Persistent: persistent, Combine: func(a interface{}, b interface{}) interface{} {
+		if b.(int) > a.(int) {
+			return b
+		}
+		return a
+	}}
+}
+
+// Or is true once any worker contributed true
+func Or(persistent bool) Aggregator {
+	return Aggregator{Zero: false, Persistent: persistent, Combine: func(a interface{}, b interface{}) interface{} {
+		return a.(bool) || b.(bool)
+	}}
+}
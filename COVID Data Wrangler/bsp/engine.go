@@ -0,0 +1,202 @@
+/*
+Package bsp runs bulk synchronous parallel programs. A program is a compute function that
+every worker runs once per superstep. Between supersteps the workers exchange messages,
+which are delivered at the start of the next superstep, and their contributions to the
+aggregators are combined into one value per aggregator.
+
+A worker that votes to halt is not run again until it receives a message. The program ends
+once every worker has halted and no messages are in flight, or after MaxSupersteps.
+*/
+package bsp
+
+import (
+	"sync"
+)
+
+// Program is a BSP program whose workers exchange messages of type M
+type Program[M any] struct {
+	// Compute is run by every active worker in every superstep
+	Compute func(worker *Worker[M])
+	// Combiner, if set, combines two messages for the same worker into one, so that a worker
+	// receives at most one message per superstep. It may modify and return into
+	Combiner func(into M, from M) M
+	// Aggregators are the aggregators workers may contribute to, by name
+	Aggregators map[string]Aggregator
+	// MaxSupersteps stops the program after this many supersteps, 0 for no limit
+	MaxSupersteps int
+}
+
+// Stats is what a finished run of a program reports
+type Stats struct {
+	Supersteps int                    // number of supersteps run
+	Aggregated map[string]interface{} // the value of every aggregator after the last superstep
+}
+
+/*
+Worker is the handle through which a compute function sees its superstep. It must only be
+used by the compute function it was passed to, during that call.
+*/
+type Worker[M any] struct {
+	ID        int
+	superstep int
+	engine    *engine[M]
+	messages  []M
+	halted    bool
+	partials  map[string]interface{} // contributions to the aggregators in this superstep
+}
+
+// engine is the state of one run of a program
+type engine[M any] struct {
+	program    Program[M]
+	workers    []*Worker[M]
+	outboxes   [2][][][]M // by superstep parity, sender, receiver. Each sender only writes its own row
+	aggregated map[string]interface{}
+}
+
+// Superstep returns the number of the current superstep, starting from 0
+func (worker *Worker[M]) Superstep() int {
+	return worker.superstep
+}
+
+// NumWorkers returns the number of workers running the program
+func (worker *Worker[M]) NumWorkers() int {
+	return len(worker.engine.workers)
+}
+
+// Messages returns the messages sent to the worker in the previous superstep, in the order of their senders
+func (worker *Worker[M]) Messages() []M {
+	return worker.messages
+}
+
+// Send sends msg to worker to, which receives it in the next superstep
+func (worker *Worker[M]) Send(to int, msg M) {
+	outbox := worker.engine.outboxes[worker.superstep%2][worker.ID]
+	if combine := worker.engine.program.Combiner; combine != nil && len(outbox[to]) > 0 {
+		outbox[to][0] = combine(outbox[to][0], msg)
+		return
+	}
+	outbox[to] = append(outbox[to], msg)
+}
+
+// VoteToHalt deactivates the worker after this superstep, until it receives a message
+func (worker *Worker[M]) VoteToHalt() {
+	worker.halted = true
+}
+
+// Aggregate contributes value to the aggregator name
+func (worker *Worker[M]) Aggregate(name string, value interface{}) {
+	aggregator := worker.engine.program.Aggregators[name]
+	partial, ok := worker.partials[name]
+	if !ok {
+		partial = aggregator.Zero
+	}
+	worker.partials[name] = aggregator.Combine(partial, value)
+}
+
+// Aggregated returns the value of the aggregator name as of the end of the previous superstep
+func (worker *Worker[M]) Aggregated(name string) interface{} {
+	return worker.engine.aggregated[name]
+}
+
+// receive collects the messages sent to the worker in the previous superstep, combining them if the program has a combiner
+func (worker *Worker[M]) receive() {
+	worker.messages = worker.messages[:0]
+	if worker.superstep == 0 {
+		return
+	}
+	combine := worker.engine.program.Combiner
+	for _, row := range worker.engine.outboxes[(worker.superstep-1)%2] {
+		for _, msg := range row[worker.ID] {
+			if combine != nil && len(worker.messages) > 0 {
+				worker.messages[0] = combine(worker.messages[0], msg)
+			} else {
+				worker.messages = append(worker.messages, msg)
+			}
+		}
+		row[worker.ID] = nil
+	}
+}
+
+/*
+Run runs program on numWorkers workers until it halts. Every superstep runs the active workers
+on goroutines of their own: each first collects the messages sent to it, in parallel with the
+others, then computes. Once they are all done, which is the barrier, the aggregators are
+combined and the next superstep starts. Messages are double-buffered by the parity of the
+superstep, so workers send into one set of outboxes while reading from the other.
+*/
+func Run[M any](numWorkers int, program Program[M]) Stats {
+	engine := &engine[M]{program: program, aggregated: make(map[string]interface{})}
+	for parity := range engine.outboxes {
+		engine.outboxes[parity] = make([][][]M, numWorkers)
+		for sender := range engine.outboxes[parity] {
+			engine.outboxes[parity][sender] = make([][]M, numWorkers)
+		}
+	}
+	for name, aggregator := range program.Aggregators {
+		engine.aggregated[name] = aggregator.Zero
+	}
+	engine.workers = make([]*Worker[M], numWorkers)
+	for id := range engine.workers {
+		engine.workers[id] = &Worker[M]{ID: id, engine: engine}
+	}
+
+	superstep := 0
+	for ; program.MaxSupersteps == 0 || superstep < program.MaxSupersteps; superstep++ {
+		active := engine.active(superstep)
+		if len(active) == 0 {
+			break
+		}
+		var group sync.WaitGroup
+		for _, worker := range active {
+			group.Add(1)
+			go func(worker *Worker[M]) {
+				defer group.Done()
+				worker.superstep = superstep
+				worker.halted = false
+				worker.partials = make(map[string]interface{})
+				worker.receive()
+				program.Compute(worker)
+			}(worker)
+		}
+		group.Wait()
+		engine.aggregate(active)
+	}
+	return Stats{Supersteps: superstep, Aggregated: engine.aggregated}
+}
+
+// active returns the workers to run in superstep: those that have not halted or have messages waiting
+func (engine *engine[M]) active(superstep int) []*Worker[M] {
+	active := []*Worker[M]{}
+	for _, worker := range engine.workers {
+		if superstep == 0 || !worker.halted || engine.hasMessages(superstep-1, worker.ID) {
+			active = append(active, worker)
+		}
+	}
+	return active
+}
+
+// hasMessages reports whether any worker sent a message to receiver in superstep
+func (engine *engine[M]) hasMessages(superstep int, receiver int) bool {
+	for _, row := range engine.outboxes[superstep%2] {
+		if len(row[receiver]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregate combines the contributions of the workers that ran into the aggregators
+func (engine *engine[M]) aggregate(active []*Worker[M]) {
+	for name, aggregator := range engine.program.Aggregators {
+		value := aggregator.Zero
+		if aggregator.Persistent {
+			value = engine.aggregated[name]
+		}
+		for _, worker := range active {
+			if partial, ok := worker.partials[name]; ok {
+				value = aggregator.Combine(value, partial)
+			}
+		}
+		engine.aggregated[name] = value
+	}
+}
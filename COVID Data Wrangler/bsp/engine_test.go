@@ -0,0 +1,205 @@
+package bsp
+
+import (
+	"reflect"
+	"testing"
+)
+
+// message is a test message recording where and when it was sent
+type message struct {
+	from   int
+	sentIn int
+	value  int
+}
+
+// delivery is a message as a worker saw it, with the superstep it was received in
+type delivery struct {
+	superstep int
+	msg       message
+}
+
+/*
+runLogged runs compute on numWorkers workers, returning the supersteps every worker ran in and
+the messages it received in them. Every worker only appends to its own logs, and the supersteps
+are ordered by the engine's barrier, so the logs need no locking.
+*/
+func runLogged(numWorkers int, program Program[message], compute func(worker *Worker[message])) (Stats, [][]int, [][]delivery) {
+	ran := make([][]int, numWorkers)
+	received := make([][]delivery, numWorkers)
+	program.Compute = func(worker *Worker[message]) {
+		ran[worker.ID] = append(ran[worker.ID], worker.Superstep())
+		for _, msg := range worker.Messages() {
+			received[worker.ID] = append(received[worker.ID], delivery{superstep: worker.Superstep(), msg: msg})
+		}
+		compute(worker)
+	}
+	stats := Run(numWorkers, program)
+	return stats, ran, received
+}
+
+// A message sent in superstep s must be received in s+1, not in s and not later
+func TestMessagesArriveInNextSuperstep(t *testing.T) {
+	const numWorkers = 4
+	stats, _, received := runLogged(numWorkers, Program[message]{MaxSupersteps: 10}, func(worker *Worker[message]) {
+		if worker.Superstep() < 3 {
+			to := (worker.ID + 1) % worker.NumWorkers()
+			worker.Send(to, message{from: worker.ID, sentIn: worker.Superstep()})
+		} else {
+			worker.VoteToHalt()
+		}
+	})
+	if stats.Supersteps != 4 {
+		t.Errorf("ran %v supersteps, want 4: three sending and one to halt", stats.Supersteps)
+	}
+	for id, deliveries := range received {
+		want := []delivery{}
+		for s := 1; s <= 3; s++ {
+			want = append(want, delivery{superstep: s, msg: message{from: (id + numWorkers - 1) % numWorkers, sentIn: s - 1}})
+		}
+		if !reflect.DeepEqual(deliveries, want) {
+			t.Errorf("worker %v received %+v, want %+v", id, deliveries, want)
+		}
+	}
+}
+
+/*
+With a combiner every worker must receive one message, folding everything sent to it in the
+superstep before, and no message if nothing was. Without one it receives every message, in the
+order of the senders.
+*/
+func TestCombinerFoldsPerReceiver(t *testing.T) {
+	const numWorkers = 5
+	sendAll := func(worker *Worker[message]) {
+		if worker.Superstep() == 0 {
+			worker.Send(0, message{value: worker.ID + 1})
+			worker.Send(1, message{value: 10 * (worker.ID + 1)})
+			worker.Send(2, message{value: 1})
+			worker.Send(2, message{value: 1})
+		}
+		worker.VoteToHalt()
+	}
+	sum := func(into message, from message) message {
+		into.value += from.value
+		return into
+	}
+
+	_, _, received := runLogged(numWorkers, Program[message]{Combiner: sum}, sendAll)
+	for id, want := range [][]delivery{
+		{{superstep: 1, msg: message{value: 15}}},
+		{{superstep: 1, msg: message{value: 150}}},
+		{{superstep: 1, msg: message{value: 10}}},
+		nil, nil,
+	} {
+		if !reflect.DeepEqual(received[id], want) {
+			t.Errorf("with a combiner, worker %v received %+v, want %+v", id, received[id], want)
+		}
+	}
+
+	_, _, received = runLogged(numWorkers, Program[message]{}, sendAll)
+	for sender := 0; sender < numWorkers; sender++ {
+		if got := received[0][sender].msg.value; got != sender+1 {
+			t.Errorf("without a combiner, message %v of worker 0 is %v, want the one of sender %v", sender, got, sender)
+		}
+	}
+	if len(received[2]) != 2*numWorkers {
+		t.Errorf("without a combiner, worker 2 received %v messages, want %v", len(received[2]), 2*numWorkers)
+	}
+}
+
+// Every worker must read the combined contributions of all the workers of the superstep before
+func TestAggregatorsSeeEveryWorker(t *testing.T) {
+	const numWorkers = 6
+	const supersteps = 4
+	read := make([][]interface{}, numWorkers)
+	program := Program[message]{
+		MaxSupersteps: supersteps,
+		Aggregators:   map[string]Aggregator{"sum": Sum(false), "total": Sum(true), "max": Max(false), "any": Or(false)},
+		Compute: func(worker *Worker[message]) {
+			read[worker.ID] = append(read[worker.ID], []interface{}{worker.Aggregated("sum"), worker.Aggregated("total"), worker.Aggregated("max"), worker.Aggregated("any")})
+			// two contributions each, so a worker's own partial is combined as well
+			worker.Aggregate("sum", worker.ID)
+			worker.Aggregate("sum", 1)
+			worker.Aggregate("total", worker.ID+1)
+			worker.Aggregate("max", worker.ID*worker.Superstep())
+			worker.Aggregate("any", worker.ID == numWorkers-1 && worker.Superstep() == 1)
+		},
+	}
+	stats := Run(numWorkers, program)
+
+	// 0 + 1 + ... + 5 = 15
+	want := func(s int) []interface{} {
+		if s == 0 {
+			return []interface{}{0, 0, 0, false}
+		}
+		return []interface{}{15 + numWorkers, s * (15 + numWorkers), (numWorkers - 1) * (s - 1), s == 2}
+	}
+	for id, values := range read {
+		for s, got := range values {
+			if !reflect.DeepEqual(got, want(s)) {
+				t.Errorf("worker %v in superstep %v read sum, total, max, any = %v, want %v", id, s, got, want(s))
+			}
+		}
+	}
+	final := want(supersteps)
+	if got := []interface{}{stats.Aggregated["sum"], stats.Aggregated["total"], stats.Aggregated["max"], stats.Aggregated["any"]}; !reflect.DeepEqual(got, final) {
+		t.Errorf("the run ended with %v, want %v", got, final)
+	}
+}
+
+// A halted worker must not run again until a message is sent to it, and then only in the superstep that delivers it
+func TestHaltedWorkersWakeOnlyForMessages(t *testing.T) {
+	const numWorkers = 3
+	stats, ran, received := runLogged(numWorkers, Program[message]{MaxSupersteps: 20}, func(worker *Worker[message]) {
+		switch {
+		case worker.ID == 0 && worker.Superstep() < 2:
+			return
+		case worker.ID == 0 && worker.Superstep() == 2:
+			worker.Send(1, message{from: 0, sentIn: 2})
+		case worker.ID == 2 && worker.Superstep() < 5:
+			return // stays active, without sending anything
+		}
+		worker.VoteToHalt()
+	})
+	wantRan := [][]int{{0, 1, 2}, {0, 3}, {0, 1, 2, 3, 4, 5}}
+	if !reflect.DeepEqual(ran, wantRan) {
+		t.Errorf("workers ran in supersteps %v, want %v", ran, wantRan)
+	}
+	if want := []delivery{{superstep: 3, msg: message{from: 0, sentIn: 2}}}; !reflect.DeepEqual(received[1], want) {
+		t.Errorf("worker 1 received %+v, want %+v", received[1], want)
+	}
+	if stats.Supersteps != 6 {
+		t.Errorf("ran %v supersteps, want 6", stats.Supersteps)
+	}
+}
+
+// The run must stop once every worker voted to halt with no message in flight, and not before
+func TestRunStopsOnceAllHalt(t *testing.T) {
+	for _, test := range []struct {
+		name  string
+		halt  int // superstep all workers halt in
+		relay bool
+		want  int
+	}{
+		{"halting at once", 0, false, 1},
+		{"halting later", 3, false, 4},
+		// every worker halts in superstep 3 but sends a message, so all are woken once more
+		{"halting with messages in flight", 3, true, 5},
+	} {
+		stats, _, _ := runLogged(4, Program[message]{MaxSupersteps: 100}, func(worker *Worker[message]) {
+			if worker.Superstep() >= test.halt {
+				if test.relay && worker.Superstep() == test.halt {
+					worker.Send((worker.ID+1)%worker.NumWorkers(), message{from: worker.ID})
+				}
+				worker.VoteToHalt()
+			}
+		})
+		if stats.Supersteps != test.want {
+			t.Errorf("%v: ran %v supersteps, want %v", test.name, stats.Supersteps, test.want)
+		}
+	}
+
+	stats := Run(4, Program[message]{MaxSupersteps: 7, Compute: func(worker *Worker[message]) {}})
+	if stats.Supersteps != 7 {
+		t.Errorf("workers that never halt ran %v supersteps, want MaxSupersteps 7", stats.Supersteps)
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"proj3/benchmark"
 	"proj3/modes"
 	"proj3/utils"
+	"proj3/verify"
 	"strconv"
 	"strings"
 )
@@ -13,14 +16,15 @@ func validArgs(args []string) bool {
 	if len(args) < 6 {
 		return false
 	}
-	if strings.Compare(args[0], "bsp") != 0 && strings.Compare(args[0], "static") != 0 && strings.Compare(args[0], "stealing") != 0 {
+	mode, ok := modes.Lookup(args[0])
+	if !ok || strings.Compare(mode.Name, "sequential") == 0 {
 		return false
 	}
 	numThreads, _ := strconv.Atoi(args[2])
 	if numThreads < 0 {
 		return false
 	}
-	if strings.Compare(args[0], "bsp") == 0 && numThreads <= 2 {
+	if numThreads != 0 && numThreads < mode.MinThreads {
 		return false
 	}
 	size, _ := strconv.Atoi(args[1])
@@ -40,19 +44,50 @@ func validArgs(args []string) bool {
 
 func main() {
 
-	const usage = "Usage:	go run proj3/covid mode size threads zipcode month year\n" +
-		"	mode = either 'static' or 'stealing' or 'bsp'\n" +
+	const usage = "Usage:	go run proj3/covid [flags] mode size threads zipcode month year\n" +
+		"	mode = either 'static' or 'stealing' or 'bsp' or 'pipeline' or 'pool' or 'loop' or 'pregel' or 'ssp' or 'hierarchical'\n" +
 		"	size = 500 or 1000 or 3000, the number of files to be processed\n" +
-		"	threads = the number of threads (i.e., goroutines to spawn). If bsp, must be > 2 \n" +
-		"	to run sequential mode, specify thread = 0 when the mode is either static or stealing\n" +
+		"	threads = the number of threads (i.e., goroutines to spawn)\n" +
+		"	to run sequential mode, specify threads = 0 with any mode\n" +
 		"	zipcode = a possible Chicago zipcode\n" +
 		"	month = the month to display for that zipcode, must be between 1-12 \n" +
-		"	year  = the year to display for that zipcode, must be 2020 or 2021 \n"
+		"	year  = the year to display for that zipcode, must be 2020 or 2021 \n" +
+		"	flags may appear before or after the positional arguments:\n"
+
+	const subcommands = "\n" +
+		"	go run proj3/covid verify [flags] size [zipcode month year]\n" +
+		"	checks that every mode and thread count agrees with sequential, see verify -h\n" +
+		"	go run proj3/covid bench [flags]\n" +
+		"	times every mode, size and thread count in-process, see bench -h\n"
+
+	// Subcommands
+	if len(os.Args) > 1 && strings.Compare(os.Args[1], "verify") == 0 {
+		os.Exit(verify.Main(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && strings.Compare(os.Args[1], "bench") == 0 {
+		os.Exit(benchmark.Main(os.Args[2:]))
+	}
 
 	// Parse the arguments and check if they are valid
-	args := os.Args[1:]
+	fs := flag.NewFlagSet("covid", flag.ContinueOnError)
+	opts := &modes.Options{}
+	opts.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+		fmt.Fprint(fs.Output(), subcommands)
+	}
+	args, err := utils.ParseFlags(fs, os.Args[1:])
+	if err != nil {
+		return
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Println(err)
+		return
+	}
 	if !validArgs(args) {
-		fmt.Println(usage)
+		fs.SetOutput(os.Stdout)
+		fs.Usage()
 		return
 	}
 	mode := args[0]
@@ -67,26 +102,11 @@ func main() {
 
 	// Sequential mode:
 	if numThreads == 0 {
-		modes.RunSequential(&arguments, size)
-		return
-	}
-
-	// Static distribution mode:
-	if strings.Compare(mode, "static") == 0 {
-		modes.RunStatic(&arguments, size, numThreads)
-		return
-	}
-
-	// Work stealing mode:
-	if strings.Compare(mode, "stealing") == 0 {
-		modes.RunStealing(&arguments, size, numThreads)
-		return
-	}
-
-	// BSP mode (thread number must be > 2):
-	if strings.Compare(mode, "bsp") == 0 {
-		modes.RunBSP(numThreads, &arguments, size)
-		return
+		mode = "sequential"
 	}
 
+	// Run the selected mode and print out the result to console
+	selected, _ := modes.Lookup(mode)
+	result := selected.Run(&arguments, size, numThreads, opts)
+	fmt.Println(result)
 }
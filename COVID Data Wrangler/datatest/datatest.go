@@ -0,0 +1,91 @@
+/*
+Package datatest writes the data directories the tests of the other packages run against. The
+modes read data file i from utils.FilePath(i), relative to the working directory, so a test runs
+from a temporary work directory next to a temporary data directory.
+*/
+package datatest
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"proj3/utils"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Header is the header line of a generated data file, with the columns utils parses in place
+const Header = "ZIP Code,Week Number,Week Start,Week End,Cases - Weekly,a,b,c,Tests - Weekly,d,e,f,g,h,Deaths - Weekly,i"
+
+// OwnZipcode is the zipcode of the record every file generated by Covid holds on its own
+const OwnZipcode = "60699"
+
+/*
+WithFiles writes contents[i] as data file i and runs the rest of the test from a work directory
+next to the data directory, going back to the previous working directory when the test is done.
+The tests of a package calling it must not run in parallel.
+*/
+func WithFiles(t testing.TB, contents map[int]string) {
+	t.Helper()
+	root := t.TempDir()
+	for _, dir := range []string{"data", "work"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for fileNum, content := range contents {
+		if err := os.WriteFile(filepath.Join(root, "data", filepath.Base(utils.FilePath(fileNum))), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Join(root, "work")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(cwd) })
+}
+
+/*
+Covid returns size data files drawn from seed, the way the real data looks to the modes: the
+same records turn up in several files with the same values, some of them miss a value and are
+skipped, and one file in ten is ten times larger than the others, so that there is load to
+balance. Every file holds rows records, or ten times as many. File i also holds a record of its
+own, for OwnZipcode on the i-th day from March 1st 2020, so that querying OwnZipcode for March
+and April 2020 tells whether any of the first 61 files was lost.
+*/
+func Covid(size int, rows int, seed int64) map[int]string {
+	rng := rand.New(rand.NewSource(seed))
+	records := []string{}
+	first := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	for zip := 60600; zip < 60610; zip++ {
+		for week := 0; week < 60; week++ {
+			start := first.AddDate(0, 0, 7*week).Format("01/02/2006") // weekly from March 2020 to April 2021
+			tests := fmt.Sprint(rng.Intn(9000))
+			if rng.Intn(20) == 0 {
+				tests = ""
+			}
+			records = append(records, fmt.Sprintf("%v,x,%v,y,%v,,,,%v,,,,,,%v,", zip, start, rng.Intn(300), tests, rng.Intn(10)))
+		}
+	}
+	contents := make(map[int]string)
+	for i := 1; i <= size; i++ {
+		lines := rows
+		if i%10 == 0 {
+			lines *= 10
+		}
+		var content strings.Builder
+		content.WriteString(Header + "\n")
+		own := first.AddDate(0, 0, i-1).Format("01/02/2006")
+		content.WriteString(fmt.Sprintf("%v,x,%v,y,%v,,,,%v,,,,,,%v,\n", OwnZipcode, own, i, 10*i, i%3))
+		for j := 0; j < lines; j++ {
+			content.WriteString(records[rng.Intn(len(records))] + "\n")
+		}
+		contents[i] = content.String()
+	}
+	return contents
+}
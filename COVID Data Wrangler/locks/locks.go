@@ -0,0 +1,66 @@
+/*
+Package locks provides spin locks for short critical sections, such as merging a worker's
+records into the global records. Every lock implements sync.Locker, so they can be swapped for
+each other and for sync.Mutex to measure which one suits a thread count best.
+
+A waiter only spins for a while before it starts yielding its processor on every check, see
+spinner, since with more goroutines than processors the holder may be descheduled and would
+otherwise wait for the spinning waiters' time slices to run out before it can unlock.
+*/
+package locks
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Locks are the available lock constructors by name
+var Locks = map[string]func() sync.Locker{
+	"tas":     NewTAS,
+	"ttas":    NewTTAS,
+	"backoff": NewBackoff,
+	"ticket":  NewTicket,
+	"clh":     NewCLH,
+	"mcs":     NewMCS,
+	"mutex":   NewMutex,
+}
+
+// Names lists the names in Locks, sorted
+func Names() []string {
+	names := []string{}
+	for name := range Locks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lookup returns the constructor registered under name
+func Lookup(name string) (func() sync.Locker, error) {
+	newLock, ok := Locks[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown lock %q, want one of %v", name, Names())
+	}
+	return newLock, nil
+}
+
+// NewMutex returns a sync.Mutex, which spins briefly and then parks the goroutine
+func NewMutex() sync.Locker {
+	return &sync.Mutex{}
+}
+
+// spinBudget is how many times a waiter checks a flag before it yields with runtime.Gosched
+const spinBudget = 100
+
+// spinner counts the checks of one waiter: the first spinBudget of them spin, the rest yield
+type spinner int
+
+func (spins *spinner) spin() {
+	if *spins < spinBudget {
+		*spins++
+		return
+	}
+	runtime.Gosched()
+}
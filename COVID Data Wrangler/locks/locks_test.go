@@ -0,0 +1,68 @@
+package locks
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Every lock must let exactly one goroutine at a time increment the counter, so no increment is lost
+func TestMutualExclusion(t *testing.T) {
+	const goroutines = 8
+	const increments = 2000
+	for name, newLock := range Locks {
+		lock := newLock()
+		counter := 0
+		var group sync.WaitGroup
+		for i := 0; i < goroutines; i++ {
+			group.Add(1)
+			go func() {
+				defer group.Done()
+				for j := 0; j < increments; j++ {
+					lock.Lock()
+					counter++
+					lock.Unlock()
+				}
+			}()
+		}
+		group.Wait()
+		if counter != goroutines*increments {
+			t.Errorf("%v: counter is %v, want %v", name, counter, goroutines*increments)
+		}
+	}
+}
+
+/*
+With more goroutines than processors a holder is often descheduled inside the critical section,
+here on purpose. Waiters that never yield would each spin out a whole time slice before it gets
+to unlock, which took minutes for the FIFO locks, so every lock must hand over well within that.
+*/
+func TestOversubscribedHolderMakesProgress(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	for _, name := range Names() {
+		lock := Locks[name]()
+		done := make(chan struct{})
+		go func() {
+			var group sync.WaitGroup
+			for i := 0; i < 16; i++ {
+				group.Add(1)
+				go func() {
+					defer group.Done()
+					for j := 0; j < 100; j++ {
+						lock.Lock()
+						runtime.Gosched()
+						lock.Unlock()
+					}
+				}()
+			}
+			group.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%v: 16 goroutines on one processor did not take the lock 1600 times in 10s", name)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package locks
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+/*
+Ticket is a ticket lock: a waiter draws the next ticket and spins until it is served, so the
+lock is granted in arrival order. All waiters still spin on the same counter.
+*/
+type Ticket struct {
+	next    uint32
+	serving uint32
+}
+
+func NewTicket() sync.Locker {
+	return &Ticket{}
+}
+
+func (lock *Ticket) Lock() {
+	ticket := atomic.AddUint32(&lock.next, 1) - 1
+	var spins spinner
+	for atomic.LoadUint32(&lock.serving) != ticket {
+		spins.spin()
+	}
+}
+
+func (lock *Ticket) Unlock() {
+	atomic.AddUint32(&lock.serving, 1)
+}
+
+type clhNode struct {
+	locked int32
+}
+
+/*
+CLH is the queue lock of Craig, Landin and Hagersten. Waiters form an implicit queue by
+swapping their node into the tail, and each one spins on the node of its predecessor, so
+every waiter spins on a different flag and the lock is granted in arrival order.
+
+sync.Locker's Unlock takes no node, so the holder's node is kept in the lock. Only the holder
+reads or writes it, and a new node is allocated for every acquisition instead of recycling
+the predecessor's.
+*/
+type CLH struct {
+	tail   unsafe.Pointer // *clhNode of the last waiter
+	holder *clhNode
+}
+
+func NewCLH() sync.Locker {
+	return &CLH{tail: unsafe.Pointer(&clhNode{})}
+}
+
+func (lock *CLH) Lock() {
+	node := &clhNode{locked: 1}
+	pred := (*clhNode)(atomic.SwapPointer(&lock.tail, unsafe.Pointer(node)))
+	var spins spinner
+	for atomic.LoadInt32(&pred.locked) == 1 {
+		spins.spin()
+	}
+	lock.holder = node
+}
+
+func (lock *CLH) Unlock() {
+	atomic.StoreInt32(&lock.holder.locked, 0)
+}
+
+type mcsNode struct {
+	locked int32
+	next   unsafe.Pointer // *mcsNode of the successor
+}
+
+/*
+MCS is the queue lock of Mellor-Crummey and Scott. Like CLH every waiter spins on its own
+flag, but the queue is linked explicitly: a waiter links itself behind its predecessor, which
+hands the lock on by clearing the successor's flag. A holder without a successor resets the
+tail, unless someone swapped into the tail and is about to link itself, in which case it
+waits for the link first.
+*/
+type MCS struct {
+	tail   unsafe.Pointer // *mcsNode of the last waiter, nil when free
+	holder *mcsNode
+}
+
+func NewMCS() sync.Locker {
+	return &MCS{}
+}
+
+func (lock *MCS) Lock() {
+	node := &mcsNode{locked: 1}
+	pred := (*mcsNode)(atomic.SwapPointer(&lock.tail, unsafe.Pointer(node)))
+	if pred != nil {
+		atomic.StorePointer(&pred.next, unsafe.Pointer(node))
+		var spins spinner
+		for atomic.LoadInt32(&node.locked) == 1 {
+			spins.spin()
+		}
+	}
+	lock.holder = node
+}
+
+func (lock *MCS) Unlock() {
+	node := lock.holder
+	next := (*mcsNode)(atomic.LoadPointer(&node.next))
+	if next == nil {
+		if atomic.CompareAndSwapPointer(&lock.tail, unsafe.Pointer(node), nil) {
+			return
+		}
+		var spins spinner
+		for next == nil {
+			spins.spin()
+			next = (*mcsNode)(atomic.LoadPointer(&node.next))
+		}
+	}
+	atomic.StoreInt32(&next.locked, 0)
+}
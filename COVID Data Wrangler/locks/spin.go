@@ -0,0 +1,96 @@
+package locks
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TAS is a test-and-set lock: every waiter keeps trying to swap the flag, and so keeps
+// invalidating the cache line for all the others
+type TAS struct {
+	flag int32
+}
+
+func NewTAS() sync.Locker {
+	return &TAS{}
+}
+
+func (lock *TAS) Lock() {
+	var spins spinner
+	for atomic.SwapInt32(&lock.flag, 1) == 1 {
+		spins.spin()
+	}
+}
+
+func (lock *TAS) Unlock() {
+	atomic.StoreInt32(&lock.flag, 0)
+}
+
+// TTAS is a test-and-test-and-set lock: waiters spin reading the flag from their own cache,
+// and only try to take it once it looks free. This is the lock the modes hand-rolled before
+type TTAS struct {
+	flag int32
+}
+
+func NewTTAS() sync.Locker {
+	return &TTAS{}
+}
+
+func (lock *TTAS) Lock() {
+	var spins spinner
+	for {
+		for atomic.LoadInt32(&lock.flag) == 1 {
+			spins.spin()
+		} // spin while lock is taken
+		if atomic.CompareAndSwapInt32(&lock.flag, 0, 1) {
+			return
+		}
+	}
+}
+
+func (lock *TTAS) Unlock() {
+	atomic.StoreInt32(&lock.flag, 0)
+}
+
+// bounds of the random delay a Backoff waiter sleeps after losing the race for the lock
+const (
+	minBackoff = time.Microsecond
+	maxBackoff = time.Millisecond
+)
+
+/*
+Backoff is a TTAS lock whose waiters back off after losing the race for the flag, sleeping
+for a random time up to a limit that doubles with every loss. Losing means others contend for
+the lock too, so backing off lets the winner finish without the others hammering the flag,
+and sleeping frees the core.
+*/
+type Backoff struct {
+	flag int32
+}
+
+func NewBackoff() sync.Locker {
+	return &Backoff{}
+}
+
+func (lock *Backoff) Lock() {
+	limit := minBackoff
+	var spins spinner
+	for {
+		for atomic.LoadInt32(&lock.flag) == 1 {
+			spins.spin()
+		} // spin while lock is taken
+		if atomic.CompareAndSwapInt32(&lock.flag, 0, 1) {
+			return
+		}
+		time.Sleep(time.Duration(rand.Int63n(int64(limit))) + 1)
+		if limit < maxBackoff {
+			limit *= 2
+		}
+	}
+}
+
+func (lock *Backoff) Unlock() {
+	atomic.StoreInt32(&lock.flag, 0)
+}
@@ -0,0 +1,123 @@
+package merge
+
+import (
+	"proj3/trace"
+	"proj3/utils"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Hierarchical merges in two levels. The workers are grouped by their IDs into groups of
+groupSize consecutive workers, the last group taking what is left, and every group has its
+own records behind its own lock. A file is merged into the records of the group it was handed
+to, whoever runs it, so a worker only waits for the workers of that group.
+
+Every group is told with Expect how many files it was handed. The worker that merges the last
+of them also merges the group's records into the global records, behind one more lock, right
+away, while the other groups are still parsing. So it is not a fixed leader that merges a
+group, but whichever worker completes it, and with P workers the global lock is only taken
+P/groupSize times in all. Result merges the groups that have not been merged yet, e.g. the
+ones merged into with Merge, which does not count files.
+*/
+type Hierarchical struct {
+	groupSize int
+	groups    []*Partial
+	locks     []sync.Locker
+	remaining []int64 // files expected by every group that have not been merged yet
+	merged    []int32 // set to 1 once a group has been merged into the global records
+	global    *Partial
+	lock      sync.Locker
+	recorder  *trace.Recorder
+
+	groupNanos  int64 // time spent merging into the groups, summed over the workers
+	globalNanos int64 // time spent merging into the global records, summed over the groups
+	early       int32 // groups merged into the global records before Result
+}
+
+// NewHierarchical returns a merger for workers 0 to numWorkers-1 in groups of groupSize
+func NewHierarchical(numWorkers int, groupSize int, newLock func() sync.Locker, recorder *trace.Recorder) *Hierarchical {
+	numGroups := (numWorkers + groupSize - 1) / groupSize
+	merger := &Hierarchical{groupSize: groupSize, groups: make([]*Partial, numGroups), locks: make([]sync.Locker, numGroups),
+		remaining: make([]int64, numGroups), merged: make([]int32, numGroups), global: NewPartial(), lock: newLock(), recorder: recorder}
+	for i := range merger.groups {
+		merger.groups[i] = NewPartial()
+		merger.locks[i] = newLock()
+	}
+	return merger
+}
+
+// Expect adds files to the files handed to the group of worker. It must be called before any file is merged
+func (merger *Hierarchical) Expect(worker int, files int) {
+	merger.remaining[worker/merger.groupSize] += int64(files)
+}
+
+// Merge merges records into the group of worker, without counting them as one of its files
+func (merger *Hierarchical) Merge(worker int, fileNum int, records map[string][]int) {
+	merger.mergeGroup(worker/merger.groupSize, worker, fileNum, records)
+}
+
+/*
+MergeFile merges the records of a file handed to the group of owner, as run by worker. If it is
+the group's last file, worker goes on to merge the group into the global records. A file that
+failed is merged with nil records, so that its group is still completed.
+*/
+func (merger *Hierarchical) MergeFile(owner int, worker int, fileNum int, records map[string][]int) {
+	group := owner / merger.groupSize
+	merger.mergeGroup(group, worker, fileNum, records)
+	if atomic.AddInt64(&merger.remaining[group], -1) == 0 {
+		merger.mergeGlobal(group, worker)
+		atomic.AddInt32(&merger.early, 1)
+	}
+}
+
+func (merger *Hierarchical) mergeGroup(group int, worker int, fileNum int, records map[string][]int) {
+	start := time.Now()
+	lockStart := merger.recorder.Now()
+	merger.locks[group].Lock()
+	merger.recorder.Record(worker, trace.Lock, fileNum, lockStart)
+	mergeStart := merger.recorder.Now()
+	merger.groups[group].Add(records)
+	merger.recorder.Record(worker, trace.Merge, fileNum, mergeStart)
+	merger.locks[group].Unlock()
+	atomic.AddInt64(&merger.groupNanos, int64(time.Since(start)))
+}
+
+// mergeGlobal merges group into the global records as worker, unless it has been already
+func (merger *Hierarchical) mergeGlobal(group int, worker int) {
+	if !atomic.CompareAndSwapInt32(&merger.merged[group], 0, 1) {
+		return
+	}
+	start := time.Now()
+	lockStart := merger.recorder.Now()
+	merger.lock.Lock()
+	merger.recorder.Record(worker, trace.Lock, 0, lockStart)
+	mergeStart := merger.recorder.Now()
+	merger.global.Add(merger.groups[group].Records)
+	merger.recorder.Record(worker, trace.Merge, 0, mergeStart)
+	merger.lock.Unlock()
+	atomic.AddInt64(&merger.globalNanos, int64(time.Since(start)))
+}
+
+/*
+Result merges the groups that have not been merged into the global records yet, as their first
+workers, and returns the global records. It may only be called once every file has been merged.
+*/
+func (merger *Hierarchical) Result() *utils.Result {
+	for group := range merger.groups {
+		merger.mergeGlobal(group, group*merger.groupSize)
+	}
+	global := merger.global
+	return &utils.Result{TotalCases: global.Cases, TotalTests: global.Tests, TotalDeaths: global.Deaths, Records: global.Records}
+}
+
+/*
+LevelTimes returns the time spent merging at each level, into the groups summed over the
+workers and into the global records summed over the groups, and the number of groups that were
+merged into the global records as soon as their last file was done rather than by Result. It
+may only be called after Result.
+*/
+func (merger *Hierarchical) LevelTimes() (group time.Duration, global time.Duration, early int) {
+	return time.Duration(atomic.LoadInt64(&merger.groupNanos)), time.Duration(atomic.LoadInt64(&merger.globalNanos)), int(atomic.LoadInt32(&merger.early))
+}
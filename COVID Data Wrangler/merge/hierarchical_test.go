@@ -0,0 +1,52 @@
+package merge
+
+import (
+	"proj3/locks"
+	"testing"
+)
+
+// A group is merged into the global records by the worker merging its last file, before Result
+func TestGroupMergedWhenItsLastFileIsDone(t *testing.T) {
+	merger := NewHierarchical(4, 2, locks.NewTTAS, nil)
+	merger.Expect(0, 1)
+	merger.Expect(1, 1)
+	merger.Expect(2, 1)
+
+	// worker 3 of the second group runs the first file of the first group, so it is merged there
+	merger.MergeFile(0, 3, 1, map[string][]int{"a": {1, 2, 3}})
+	if _, contains := merger.global.Records["a"]; contains {
+		t.Fatalf("the first group was merged with one of its files left")
+	}
+	merger.MergeFile(1, 0, 2, map[string][]int{"a": {10, 20, 30}, "b": {4, 5, 6}})
+	if val := merger.global.Records["a"]; len(val) != 3 || val[0] != 1 {
+		t.Fatalf("global record a = %v after the first group's last file, want the first file's [1 2 3]", val)
+	}
+	if _, contains := merger.global.Records["c"]; contains {
+		t.Fatalf("the second group was merged with one of its files left")
+	}
+
+	merger.MergeFile(2, 2, 3, map[string][]int{"c": {7, 8, 9}})
+	result := merger.Result()
+	if _, _, early := merger.LevelTimes(); early != 2 {
+		t.Errorf("%v groups merged before Result, want 2", early)
+	}
+	if result.TotalCases != 1+4+7 || result.TotalTests != 2+5+8 || result.TotalDeaths != 3+6+9 || len(result.Records) != 3 {
+		t.Errorf("result = %v cases, %v tests, %v deaths in %v records, want 12, 15, 18 in 3",
+			result.TotalCases, result.TotalTests, result.TotalDeaths, len(result.Records))
+	}
+}
+
+// Groups that are never completed, e.g. ones merged into with Merge, are merged by Result
+func TestResultMergesUnfinishedGroups(t *testing.T) {
+	merger := NewHierarchical(3, 2, locks.NewTTAS, nil)
+	merger.Expect(0, 2)
+	merger.MergeFile(0, 0, 1, map[string][]int{"a": {1, 1, 1}})
+	merger.Merge(2, 2, map[string][]int{"b": {2, 2, 2}})
+	result := merger.Result()
+	if _, _, early := merger.LevelTimes(); early != 0 {
+		t.Errorf("%v groups merged before Result, want 0", early)
+	}
+	if result.TotalCases != 3 || len(result.Records) != 2 {
+		t.Errorf("result = %v cases in %v records, want 3 in 2", result.TotalCases, len(result.Records))
+	}
+}
@@ -0,0 +1,52 @@
+/*
+Package merge combines the deduplicated records that workers produce into the global result.
+The first record merged under a key wins, and only that record counts towards the totals.
+*/
+package merge
+
+import (
+	"proj3/trace"
+	"proj3/utils"
+	"sync"
+)
+
+// A Merger collects the records of every piece of work into one result
+type Merger interface {
+	// Merge adds the records worker produced for fileNum (0 for records of several files).
+	// It may be called by any number of workers at once
+	Merge(worker int, fileNum int, records map[string][]int)
+	// Result returns the merged records and their totals. Only call it once every Merge returned
+	Result() *utils.Result
+}
+
+/*
+Locked is a single global record map guarded by one lock. Every merge holds the lock for its
+whole duration, so workers that finish at the same time queue up behind each other.
+*/
+type Locked struct {
+	lock     sync.Locker
+	result   *utils.Result
+	recorder *trace.Recorder
+}
+
+// NewLocked returns a merger guarded by lock, recording lock and merge spans into recorder
+func NewLocked(lock sync.Locker, recorder *trace.Recorder) *Locked {
+	return &Locked{lock: lock, result: utils.NewResult(), recorder: recorder}
+}
+
+func (merger *Locked) Merge(worker int, fileNum int, records map[string][]int) {
+	// enter the critical section by updating the global values
+	// exit the critical section after finishing work
+	lockStart := merger.recorder.Now()
+	merger.lock.Lock()
+	merger.recorder.Record(worker, trace.Lock, fileNum, lockStart)
+	mergeStart := merger.recorder.Now()
+	result := merger.result
+	utils.UpdateGlobal(records, result.Records, &result.TotalCases, &result.TotalTests, &result.TotalDeaths)
+	merger.recorder.Record(worker, trace.Merge, fileNum, mergeStart)
+	merger.lock.Unlock()
+}
+
+func (merger *Locked) Result() *utils.Result {
+	return merger.result
+}
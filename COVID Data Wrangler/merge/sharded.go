@@ -0,0 +1,95 @@
+package merge
+
+import (
+	"proj3/trace"
+	"proj3/utils"
+	"sync"
+)
+
+// shard is one partition of a Sharded merger, with the totals of the records it holds
+type shard struct {
+	lock    sync.Locker
+	records map[string][]int
+	cases   int
+	tests   int
+	deaths  int
+}
+
+/*
+Sharded partitions the records by a hash of their key into shards, each with its own lock,
+record map and partial totals. A merge sorts its records by shard and then takes each shard's
+lock once, so workers only wait for each other when they update the same shard at the same
+time. Workers start at different shards to make that less likely. The partial totals are
+summed up by Result.
+*/
+type Sharded struct {
+	shards   []*shard
+	recorder *trace.Recorder
+}
+
+// NewSharded returns a merger with numShards shards, each guarded by a lock made by newLock
+func NewSharded(numShards int, newLock func() sync.Locker, recorder *trace.Recorder) *Sharded {
+	merger := &Sharded{shards: make([]*shard, numShards), recorder: recorder}
+	for i := range merger.shards {
+		// allocated one by one, so that shards updated by different workers do not share cache lines
+		merger.shards[i] = &shard{lock: newLock(), records: make(map[string][]int)}
+	}
+	return merger
+}
+
+// KeyHash hashes a record key with 32-bit FNV-1a, without the allocation of hash/fnv
+func KeyHash(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return hash
+}
+
+func (merger *Sharded) shardOf(key string) int {
+	return int(KeyHash(key) % uint32(len(merger.shards)))
+}
+
+func (merger *Sharded) Merge(worker int, fileNum int, records map[string][]int) {
+	mergeStart := merger.recorder.Now()
+	keys := make([][]string, len(merger.shards))
+	for key := range records {
+		i := merger.shardOf(key)
+		keys[i] = append(keys[i], key)
+	}
+	for n := range merger.shards {
+		i := (worker + n) % len(merger.shards)
+		if len(keys[i]) == 0 {
+			continue
+		}
+		shard := merger.shards[i]
+		shard.lock.Lock()
+		for _, key := range keys[i] {
+			if _, contains := shard.records[key]; contains {
+				continue
+			} // skip duplicate
+			val := records[key]
+			shard.records[key] = val
+			shard.cases += val[0]
+			shard.tests += val[1]
+			shard.deaths += val[2]
+		}
+		shard.lock.Unlock()
+	}
+	merger.recorder.Record(worker, trace.Merge, fileNum, mergeStart)
+}
+
+// Result combines the shards into one result
+func (merger *Sharded) Result() *utils.Result {
+	result := utils.NewResult()
+	for _, shard := range merger.shards {
+		for key, val := range shard.records {
+			result.Records[key] = val
+		}
+		result.TotalCases += shard.cases
+		result.TotalTests += shard.tests
+		result.TotalDeaths += shard.deaths
+	}
+	return result
+}
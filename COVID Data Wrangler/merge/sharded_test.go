@@ -0,0 +1,81 @@
+package merge
+
+import (
+	"fmt"
+	"proj3/locks"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// batches returns n record maps whose keys overlap: batch i holds keys i to i+width-1, each valued by value(batch, key)
+func batches(n int, width int, value func(batch int, key int) []int) []map[string][]int {
+	all := make([]map[string][]int, n)
+	for i := range all {
+		all[i] = make(map[string][]int)
+		for key := i; key < i+width; key++ {
+			all[i][fmt.Sprintf("zipcode:%v,time:x", key)] = value(i, key)
+		}
+	}
+	return all
+}
+
+// Merged one after another, the first record of every key must win and be the only one counted, as with Locked
+func TestShardedFirstWinsLikeLocked(t *testing.T) {
+	// a later batch carries different values for the keys it shares with earlier ones
+	all := batches(40, 25, func(batch int, key int) []int { return []int{batch, key, batch + key} })
+	locked := NewLocked(locks.NewTTAS(), nil)
+	for i, records := range all {
+		locked.Merge(i%4, i+1, records)
+	}
+	want := locked.Result()
+	for _, numShards := range []int{1, 2, 7, 64} {
+		sharded := NewSharded(numShards, locks.NewTTAS, nil)
+		for i, records := range all {
+			sharded.Merge(i%4, i+1, records)
+		}
+		got := sharded.Result()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v shards: %v cases, %v tests, %v deaths in %v records, want %v, %v, %v in %v",
+				numShards, got.TotalCases, got.TotalTests, got.TotalDeaths, len(got.Records),
+				want.TotalCases, want.TotalTests, want.TotalDeaths, len(want.Records))
+		}
+	}
+}
+
+// Workers merging overlapping keys at once must neither lose a key nor count one twice
+func TestShardedConcurrentMerges(t *testing.T) {
+	const workers = 8
+	const perWorker = 30
+	const width = 50
+	// every copy of a key has the same value, so the totals do not depend on who merges first
+	all := batches(workers*perWorker, width, func(batch int, key int) []int { return []int{key, 2 * key, key % 3} })
+	numKeys := workers*perWorker + width - 1
+	wantCases, wantTests, wantDeaths := 0, 0, 0
+	for key := 0; key < numKeys; key++ {
+		wantCases += key
+		wantTests += 2 * key
+		wantDeaths += key % 3
+	}
+
+	for _, numShards := range []int{1, 16} {
+		sharded := NewSharded(numShards, locks.NewTTAS, nil)
+		var group sync.WaitGroup
+		for worker := 0; worker < workers; worker++ {
+			group.Add(1)
+			go func(worker int) {
+				defer group.Done()
+				// interleaved, so that the workers keep merging the keys of their neighbours
+				for i := worker; i < len(all); i += workers {
+					sharded.Merge(worker, i+1, all[i])
+				}
+			}(worker)
+		}
+		group.Wait()
+		result := sharded.Result()
+		if len(result.Records) != numKeys || result.TotalCases != wantCases || result.TotalTests != wantTests || result.TotalDeaths != wantDeaths {
+			t.Errorf("%v shards: %v cases, %v tests, %v deaths in %v records, want %v, %v, %v in %v", numShards,
+				result.TotalCases, result.TotalTests, result.TotalDeaths, len(result.Records), wantCases, wantTests, wantDeaths, numKeys)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package merge
+
+import (
+	"proj3/trace"
+	"proj3/utils"
+	"sync"
+)
+
+// Partial is a set of deduplicated records together with their totals
+type Partial struct {
+	Records map[string][]int
+	Cases   int
+	Tests   int
+	Deaths  int
+}
+
+func NewPartial() *Partial {
+	return &Partial{Records: make(map[string][]int)}
+}
+
+// Add merges the records of from that partial does not hold yet, the ones it holds win
+func (partial *Partial) Add(from map[string][]int) {
+	for key, val := range from {
+		if _, contains := partial.Records[key]; contains {
+			continue
+		} // skip duplicate
+		partial.Records[key] = val
+		partial.Cases += val[0]
+		partial.Tests += val[1]
+		partial.Deaths += val[2]
+	}
+}
+
+/*
+overwrite merges the records of winners, replacing the records partial holds under the same
+keys and adjusting the totals to match
+*/
+func (partial *Partial) overwrite(winners map[string][]int) {
+	for key, val := range winners {
+		if old, contains := partial.Records[key]; contains {
+			partial.Cases -= old[0]
+			partial.Tests -= old[1]
+			partial.Deaths -= old[2]
+		}
+		partial.Records[key] = val
+		partial.Cases += val[0]
+		partial.Tests += val[1]
+		partial.Deaths += val[2]
+	}
+}
+
+// absorb merges from into into with into's records winning, iterating over the smaller map
+func absorb(into *Partial, from *Partial) {
+	if len(into.Records) >= len(from.Records) {
+		into.Add(from.Records)
+		return
+	}
+	winners := into.Records
+	*into, *from = *from, Partial{}
+	into.overwrite(winners)
+}
+
+/*
+Reduce merges partials pairwise in parallel, in ceil(log2(len(partials))) rounds, and returns
+the merged partial. In the round with stride s, partial i absorbs partial i+s for every i
+that is a multiple of 2s, so the pairs of a round are disjoint and are merged concurrently.
+The lower index always wins a duplicate key, the same as merging the partials one by one in
+order, and the totals stay those of the deduplicated records. The partials are merged in place.
+
+recorder gets a merge span for every pair, recorded as the worker of the lower index. It may
+be nil.
+*/
+func Reduce(partials []*Partial, recorder *trace.Recorder) *Partial {
+	if len(partials) == 0 {
+		return NewPartial()
+	}
+	for stride := 1; stride < len(partials); stride *= 2 {
+		var group sync.WaitGroup
+		for i := 0; i+stride < len(partials); i += 2 * stride {
+			group.Add(1)
+			go func(worker int) {
+				defer group.Done()
+				mergeStart := recorder.Now()
+				absorb(partials[worker], partials[worker+stride])
+				recorder.Record(worker, trace.Merge, 0, mergeStart)
+			}(i)
+		}
+		group.Wait()
+	}
+	return partials[0]
+}
+
+/*
+Tree gives every worker its own partial, which it merges into without locking. Result then
+combines the partials with Reduce, so a worker's records win over those of higher workers,
+and within a worker the records merged first win. Each worker ID must only be used by one
+goroutine at a time.
+*/
+type Tree struct {
+	partials []*Partial
+	recorder *trace.Recorder
+}
+
+// NewTree returns a merger for workers 0 to numWorkers-1
+func NewTree(numWorkers int, recorder *trace.Recorder) *Tree {
+	merger := &Tree{partials: make([]*Partial, numWorkers), recorder: recorder}
+	for i := range merger.partials {
+		merger.partials[i] = NewPartial()
+	}
+	return merger
+}
+
+func (merger *Tree) Merge(worker int, fileNum int, records map[string][]int) {
+	mergeStart := merger.recorder.Now()
+	merger.partials[worker].Add(records)
+	merger.recorder.Record(worker, trace.Merge, fileNum, mergeStart)
+}
+
+// Result reduces the partials of the workers, see Reduce. It may only be called once
+func (merger *Tree) Result() *utils.Result {
+	merged := Reduce(merger.partials, merger.recorder)
+	return &utils.Result{TotalCases: merged.Cases, TotalTests: merged.Tests, TotalDeaths: merged.Deaths, Records: merged.Records}
+}
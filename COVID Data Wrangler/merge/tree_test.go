@@ -0,0 +1,50 @@
+package merge
+
+import (
+	"fmt"
+	"proj3/locks"
+	"reflect"
+	"testing"
+)
+
+/*
+With any number of workers, odd ones leaving a partial without a pair in some rounds, a key
+merged by several workers must keep the record of the lowest of them, the same as the Locked
+merger fed the workers in order. Higher workers get more records, so that absorb also takes
+the branch that swaps the partials and overwrites the larger one.
+*/
+func TestTreeLowestWorkerWins(t *testing.T) {
+	for _, numWorkers := range []int{1, 2, 3, 5, 7, 8} {
+		merger := NewTree(numWorkers, nil)
+		locked := NewLocked(locks.NewTTAS(), nil)
+		for worker := 0; worker < numWorkers; worker++ {
+			records := map[string][]int{
+				"everyone": {worker, 10 * worker, 100 * worker},
+			}
+			if worker%2 == 1 {
+				records["odd"] = []int{worker, worker, worker}
+			}
+			if worker >= numWorkers/2 {
+				records["upper half"] = []int{worker, 1, 0}
+			}
+			for i := 0; i < 4*worker; i++ {
+				records[fmt.Sprintf("worker %v record %v", worker, i)] = []int{1, 2, 3}
+				records[fmt.Sprintf("shared %v", i)] = []int{worker, 0, 1}
+			}
+			merger.Merge(worker, 0, records)
+			locked.Merge(worker, 0, records)
+		}
+		got, want := merger.Result(), locked.Result()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v workers: %v cases, %v tests, %v deaths in %v records, want %v, %v, %v in %v", numWorkers,
+				got.TotalCases, got.TotalTests, got.TotalDeaths, len(got.Records),
+				want.TotalCases, want.TotalTests, want.TotalDeaths, len(want.Records))
+		}
+		if val := got.Records["everyone"]; val[0] != 0 {
+			t.Errorf("%v workers: worker %v won the key every worker merged, want 0", numWorkers, val[0])
+		}
+		if val, contains := got.Records["odd"]; numWorkers > 1 && (!contains || val[0] != 1) {
+			t.Errorf("%v workers: worker %v won the key of the odd workers, want 1", numWorkers, val)
+		}
+	}
+}
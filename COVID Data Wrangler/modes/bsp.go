@@ -1,130 +1,193 @@
 package modes
 
 import (
-	"fmt"
+	"proj3/barrier"
+	"proj3/merge"
+	"proj3/trace"
 	"proj3/utils"
 	"sync"
 )
 
+/*
+BSPContext is the state shared by the workers of a BSP run. There is no coordinator thread:
+every thread is a worker, they synchronize on the barrier selected in the options, and the
+merge at the barrier is shared between all of them. Every key is owned by one worker, chosen
+by its hash. While parsing, a worker sorts its records by owner into its outbox. Once everyone
+has passed the barrier, every worker merges the records it owns out of all the outboxes, so
+the workers merge disjoint keys in parallel without any locking.
+
+The outboxes are double-buffered by the parity of the superstep: the outboxes written in a
+superstep are only read after its barrier, and the ones read after it are only written again
+after the next barrier, by which time everyone is done reading them.
+*/
 type BSPContext struct {
 	// Number of Threads
 	numThreads int
 
-	// Keep track of current task and effect
-	iterIdx      int // idx of current iteration of superstep - synchronization step
-	numTasks     int // total number of tasks
-	localRecords []map[string][]int
-	args         *utils.Arguments
+	// Keep track of the tasks
+	bspTasks
+	recorder *trace.Recorder
 
 	// For global synchronization
-	globalRecords map[string]bool
-	totalCases    int
-	totalTests    int
-	totalDeaths   int
-	mutex         *sync.Mutex
-	cond          *sync.Cond
-	workersIdle   int
-	synchronizing bool
-	done          bool
+	outboxes [2][][]map[string][]int // records parsed in a superstep, by parity, worker and owner
+	owned    []map[string][]int      // records merged so far, by owner
+	merger   merge.Merger
+	barrier  barrier.Barrier
 }
 
-func initBSPContext(numThreads int, args *utils.Arguments, size int) *BSPContext {
-
-	// Initialize the basic task information (threads, number of tasks )
-	newContext := &BSPContext{numThreads: numThreads, args: args, iterIdx: 0, totalCases: 0, totalTests: 0, totalDeaths: 0}
-	newContext.numTasks = size
-	// Initialize the local records slice for workers
-	localRecords := make([]map[string][]int, numThreads)
-	newContext.localRecords = localRecords
-	newContext.globalRecords = make(map[string]bool)
+// bspTasks are the tasks of a BSP run. In every superstep, every worker runs a block of tasksPerStep consecutive tasks
+type bspTasks struct {
+	numTasks     int               // total number of tasks
+	tasksPerStep int               // tasks every worker runs per superstep
+	numSteps     int               // supersteps needed to run all the tasks
+	ranges       []utils.ByteRange // the tasks when large files are split into byte ranges, nil for one task per file
+	args         *utils.Arguments
+	failed       *failures // files that could not be split or ranges that could not be read, their records are missing
+}
 
-	// Initialize the synchronization parameters
-	var mutex sync.Mutex
-	cond := sync.NewCond(&mutex)
-	newContext.cond = cond
-	newContext.mutex = &mutex
-	newContext.workersIdle = 0
-	newContext.synchronizing = false
-	newContext.done = false
+// newBSPTasks makes a task of every file, or of every byte range with opts.ChunkBytes, for numThreads workers
+func newBSPTasks(numThreads int, args *utils.Arguments, size int, opts *Options) bspTasks {
+	tasks := bspTasks{numTasks: size, tasksPerStep: opts.Superstep, args: args, failed: &failures{}}
+	if tasks.tasksPerStep == 0 {
+		tasks.tasksPerStep = 1
+	}
+	if opts.ChunkBytes > 0 {
+		// every byte range of a large file becomes a task of its own, spreading the file over the workers of a superstep
+		tasks.ranges = []utils.ByteRange{}
+		for i := 1; i <= size; i++ {
+			fileNum := utils.GetFileNum(i)
+			ranges, err := utils.SplitFile(fileNum, opts.ChunkBytes)
+			if err != nil {
+				tasks.failed.fail(fileNum, err)
+				continue
+			}
+			tasks.ranges = append(tasks.ranges, ranges...)
+		}
+		tasks.numTasks = len(tasks.ranges)
+	}
+	perStep := numThreads * tasks.tasksPerStep
+	tasks.numSteps = (tasks.numTasks + perStep - 1) / perStep
+	return tasks
+}
 
-	return newContext
+// stepTasks returns the first and last task of worker idx in superstep step. There are none if first > last
+func (tasks *bspTasks) stepTasks(idx int, step int, numThreads int) (int, int) {
+	first := (step*numThreads+idx)*tasks.tasksPerStep + 1
+	return first, minInt(first+tasks.tasksPerStep-1, tasks.numTasks)
 }
 
-func synchronize(ctx *BSPContext) {
-	ctx.mutex.Lock()
-	for !ctx.synchronizing {
-		ctx.cond.Wait()
+/*
+parse parses task taskIdx, returning the number of the file it is part of and its records. A
+byte range that cannot be read is recorded in tasks.failed and has no records.
+*/
+func (tasks *bspTasks) parse(taskIdx int) (int, map[string][]int) {
+	if tasks.ranges != nil {
+		byteRange := tasks.ranges[taskIdx-1]
+		records, err := utils.ParseRange(tasks.args, byteRange)
+		if err != nil {
+			tasks.failed.fail(byteRange.FileNum, err)
+		}
+		return byteRange.FileNum, records
 	}
+	fileNum := utils.GetFileNum(taskIdx)
+	return fileNum, utils.ParseFile(tasks.args, fileNum)
+}
 
-	// Update all the records
-	for i := 0; i < ctx.numThreads; i++ {
-		utils.UpdateGlobal(ctx.localRecords[i], ctx.globalRecords, &ctx.totalCases, &ctx.totalTests, &ctx.totalDeaths)
-	}
+func initBSPContext(numThreads int, tasks bspTasks, newBarrier func(int) barrier.Barrier) *BSPContext {
 
-	// update idx for next iter:
-	ctx.iterIdx += 1
+	// Initialize the basic task information (threads, tasks)
+	newContext := &BSPContext{numThreads: numThreads, bspTasks: tasks}
 
-	// terminate if exceeds the quota
-	if ctx.iterIdx*ctx.numThreads > ctx.numTasks {
-		ctx.done = true
-		ctx.mutex.Unlock()
-		return
+	// Initialize the outboxes and the records every worker owns
+	for parity := range newContext.outboxes {
+		newContext.outboxes[parity] = make([][]map[string][]int, numThreads)
+	}
+	newContext.owned = make([]map[string][]int, numThreads)
+	for idx := 0; idx < numThreads; idx++ {
+		newContext.owned[idx] = make(map[string][]int)
 	}
 
-	ctx.synchronizing = false
-	ctx.mutex.Unlock()
-
-	ctx.cond.Broadcast() // signal all workers to wake up for next round
+	// Initialize the synchronization parameters
+	newContext.barrier = newBarrier(numThreads)
 
+	return newContext
 }
 
-func superStep(idx int, ctx *BSPContext) {
-
-	// Get the correct subtask for this superstep on this worker
-	curIterIdx := ctx.iterIdx
-	fileIdx := (curIterIdx*ctx.numThreads + (idx + 1))
+// owner returns the worker owning key
+func (ctx *BSPContext) owner(key string) int {
+	return int(merge.KeyHash(key) % uint32(ctx.numThreads))
+}
 
-	if fileIdx > ctx.numTasks {
-		ctx.localRecords[idx] = make(map[string][]int)
-	} else {
-		fileNum := utils.GetFileNum(fileIdx)
-		ctx.localRecords[idx] = utils.ParseFile(ctx.args, fileNum)
+/*
+superStep runs the tasks of worker idx in superstep step, sorting the records into its outbox
+by owner. The worker gets a block of consecutive tasks, so the lower workers of a superstep
+hold the earlier files, and a record already in the outbox wins over a later one.
+*/
+func superStep(idx int, step int, ctx *BSPContext) {
+	outbox := make([]map[string][]int, ctx.numThreads)
+	for owner := range outbox {
+		outbox[owner] = make(map[string][]int)
 	}
-
-	// Synchronize
-	ctx.mutex.Lock()
-	ctx.workersIdle++
-	if ctx.workersIdle == ctx.numThreads {
-		ctx.synchronizing = true
-		ctx.cond.Broadcast() // wake up synchronizer routine
+	ctx.outboxes[step%2][idx] = outbox
+
+	first, last := ctx.stepTasks(idx, step, ctx.numThreads)
+	for taskIdx := first; taskIdx <= last; taskIdx++ {
+		parseStart := ctx.recorder.Now()
+		fileNum, records := ctx.parse(taskIdx)
+		ctx.recorder.Record(idx, trace.Parse, fileNum, parseStart)
+		for key, val := range records {
+			owned := outbox[ctx.owner(key)]
+			if _, contains := owned[key]; !contains {
+				owned[key] = val
+			}
+		}
 	}
-	for ctx.synchronizing || (ctx.iterIdx == curIterIdx) {
-		ctx.cond.Wait()
+}
+
+/*
+mergeStep merges the records worker idx owns out of the outboxes of superstep step, in worker
+order. Records merged in earlier supersteps come from earlier files and win.
+*/
+func mergeStep(idx int, step int, ctx *BSPContext) {
+	mergeStart := ctx.recorder.Now()
+	for from := 0; from < ctx.numThreads; from++ {
+		utils.MergeRecords(ctx.owned[idx], ctx.outboxes[step%2][from][idx])
 	}
-	ctx.workersIdle--
-	ctx.mutex.Unlock()
+	ctx.recorder.Record(idx, trace.Merge, 0, mergeStart)
 }
 
 func ExecuteBSP(idx int, ctx *BSPContext) {
-	for {
-		if ctx.done {
-			return
-		}
-		if idx == ctx.numThreads {
-			synchronize(ctx)
-		} else {
-			superStep(idx, ctx)
-		}
+	for step := 0; step < ctx.numSteps; step++ {
+		superStep(idx, step, ctx)
+
+		// Synchronize
+		waitStart := ctx.recorder.Now()
+		ctx.barrier.Wait(idx)
+		ctx.recorder.Record(idx, trace.Barrier, 0, waitStart)
+
+		mergeStep(idx, step, ctx)
 	}
+	// the owners hold disjoint keys, so they can hand them to any merger concurrently
+	ctx.merger.Merge(idx, 0, ctx.owned[idx])
 }
 
-func RunBSP(numThreads int, args *utils.Arguments, size int) {
-	ctx := initBSPContext(numThreads-1, args, size) // Initialize your BSP context
+func RunBSP(numThreads int, args *utils.Arguments, size int, opts *Options) *utils.Result {
+	tasks := newBSPTasks(numThreads, args, size, opts)
+	ctx := initBSPContext(numThreads, tasks, opts.barrierConstructor()) // Initialize your BSP context
+	ctx.recorder = opts.newRecorder("bsp", numThreads)
+	ctx.merger = opts.newMerger(numThreads, ctx.recorder)
+
+	var group sync.WaitGroup
 	for idx := 0; idx < numThreads-1; idx++ {
-		go ExecuteBSP(idx, ctx)
+		group.Add(1)
+		go func(idx int) {
+			defer group.Done()
+			ExecuteBSP(idx, ctx)
+		}(idx)
 	}
 	ExecuteBSP(numThreads-1, ctx)
-	// final processing of the result and print out to console
-	result := fmt.Sprintf("%v,%v,%v", ctx.totalCases, ctx.totalTests, ctx.totalDeaths)
-	fmt.Println(result)
+	group.Wait()
+	ctx.failed.report()
+	opts.writeTrace(ctx.recorder)
+	return ctx.merger.Result()
 }
@@ -0,0 +1,36 @@
+package modes
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+/*
+failures records the files, or parts of files, that could not be read, so that a mode can tell
+which records are missing from its result instead of quietly leaving them out. It is safe to
+call fail from any worker.
+*/
+type failures struct {
+	failedMutex sync.Mutex
+	failed      []error
+}
+
+// fail records that the records of fileNum, or of a part of it, are missing because of err
+func (failed *failures) fail(fileNum int, err error) {
+	failed.failedMutex.Lock()
+	failed.failed = append(failed.failed, fmt.Errorf("file %v: %w", fileNum, err))
+	failed.failedMutex.Unlock()
+}
+
+// report prints the failures to stderr. It may only be called once the workers are done
+func (failed *failures) report() {
+	for _, err := range failed.failed {
+		reportMissing(err)
+	}
+}
+
+// reportMissing prints to stderr that records are missing from the result because of err
+func reportMissing(err error) {
+	fmt.Fprintf(os.Stderr, "records missing from the result: %v\n", err)
+}
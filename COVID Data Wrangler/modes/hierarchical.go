@@ -0,0 +1,52 @@
+package modes
+
+import (
+	"fmt"
+	"os"
+	"proj3/merge"
+	"proj3/stealing"
+	"proj3/utils"
+	"time"
+)
+
+/*
+RunHierarchical runs the files on a work-stealing pool like stealing, with the workers grouped
+into groups of opts.Group consecutive workers. Every file is merged into the records of the
+group it was handed to by the partition, and the worker merging a group's last file then merges
+the group into the global records while the other groups are still parsing, see
+merge.Hierarchical. An idle worker steals from its own group before it tries the others, see
+stealing.NewGroupVictims. The mode picks its own merger and victim policy, so -merge and -victim
+are ignored, with a warning if they are set to anything else.
+
+With -stats the stealing statistics are printed to stderr, followed by the time spent merging
+at each level.
+*/
+func RunHierarchical(args *utils.Arguments, size int, numThreads int, opts *Options) *utils.Result {
+	groupSize := opts.Group
+	if groupSize == 0 || groupSize > numThreads {
+		groupSize = numThreads
+	}
+	if opts.Merge != "" && opts.Merge != "global" {
+		fmt.Fprintf(os.Stderr, "hierarchical merges by groups, ignoring -merge %v\n", opts.Merge)
+	}
+	if opts.Victim != "" && opts.Victim != "random" {
+		fmt.Fprintf(os.Stderr, "hierarchical steals within groups first, ignoring -victim %v\n", opts.Victim)
+	}
+	context := &stealingContext{args: args, split: opts.Split, chunk: opts.ChunkBytes}
+	context.recorder = opts.newRecorder("hierarchical", numThreads)
+	merger := merge.NewHierarchical(numThreads, groupSize, opts.lockConstructor(), context.recorder)
+	context.groups = merger
+
+	pool := runStealing(context, size, numThreads, opts, stealing.NewGroupVictims(groupSize))
+	context.reportFailures(pool)
+	result := merger.Result()
+	opts.writeTrace(context.recorder)
+	if opts.Stats {
+		stealing.WriteStats(os.Stderr, pool.Workers())
+		group, global, early := merger.LevelTimes()
+		numGroups := (numThreads + groupSize - 1) / groupSize
+		fmt.Fprintf(os.Stderr, "group merges: %v over %v workers in %v groups of %v\n", group.Round(time.Microsecond), numThreads, numGroups, groupSize)
+		fmt.Fprintf(os.Stderr, "global merges: %v over %v groups, %v merged as soon as their last file was done\n", global.Round(time.Microsecond), numGroups, early)
+	}
+	return result
+}
@@ -0,0 +1,153 @@
+package modes
+
+import (
+	"fmt"
+	"proj3/trace"
+	"proj3/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// loopSchedule is how the loop mode hands out the file indices, as in OpenMP's schedule clause
+type loopSchedule struct {
+	kind  string // static, dynamic or guided
+	chunk int    // chunk size, or the smallest chunk for guided. 0 for static means one block per thread
+}
+
+// parseSchedule parses "kind[,chunk]", e.g. "static", "dynamic,4" or "guided,2"
+func parseSchedule(value string) (loopSchedule, error) {
+	kind, chunkText, hasChunk := strings.Cut(value, ",")
+	schedule := loopSchedule{kind: kind}
+	switch kind {
+	case "static":
+		schedule.chunk = 0
+	case "dynamic", "guided":
+		schedule.chunk = 1
+	default:
+		return schedule, fmt.Errorf("unknown schedule %q, want static, dynamic or guided", kind)
+	}
+	if hasChunk {
+		chunk, err := strconv.Atoi(chunkText)
+		if err != nil || chunk < 1 {
+			return schedule, fmt.Errorf("schedule chunk must be a positive number, got %q", chunkText)
+		}
+		schedule.chunk = chunk
+	}
+	return schedule, nil
+}
+
+/*
+loopScheduler hands out the file indices 1 to size to numThreads workers. Static schedules
+are worked out from the worker's ID and how many chunks it took, without any shared state.
+Dynamic and guided ones claim the next chunk from a shared atomic counter of the indices
+handed out so far.
+*/
+type loopScheduler struct {
+	schedule   loopSchedule
+	size       int
+	numThreads int
+	next       int64 // indices handed out so far, for dynamic and guided
+}
+
+/*
+chunk returns the k-th chunk for worker id, as an inclusive range of file indices, and false
+once there is no work left for it.
+
+  - static without a chunk size gives every worker one block, spreading the remainder over the
+    first workers so that no block is more than one file larger than another.
+  - static,N deals out chunks of N round-robin: chunk k of worker id is chunk k*threads+id.
+  - dynamic,N claims the next N indices from the counter.
+  - guided,N claims the remaining indices divided by the number of workers, but at least N, so
+    chunks start large to keep overhead down and shrink towards the end to balance the load.
+*/
+func (scheduler *loopScheduler) chunk(id int, k int) (fileRange, bool) {
+	size, numThreads, chunk := scheduler.size, scheduler.numThreads, scheduler.schedule.chunk
+	switch scheduler.schedule.kind {
+	case "static":
+		if chunk == 0 {
+			if k > 0 {
+				return fileRange{}, false
+			}
+			block, rem := size/numThreads, size%numThreads
+			start := id*block + minInt(id, rem) + 1
+			end := start + block - 1
+			if id < rem {
+				end++
+			}
+			return fileRange{start: start, end: end}, start <= end
+		}
+		start := (k*numThreads+id)*chunk + 1
+		return fileRange{start: start, end: minInt(start+chunk-1, size)}, start <= size
+	case "dynamic":
+		end := int(atomic.AddInt64(&scheduler.next, int64(chunk)))
+		start := end - chunk + 1
+		return fileRange{start: start, end: minInt(end, size)}, start <= size
+	default: // guided
+		for {
+			claimed := atomic.LoadInt64(&scheduler.next)
+			remaining := size - int(claimed)
+			if remaining <= 0 {
+				return fileRange{}, false
+			}
+			take := remaining / numThreads
+			if take < chunk {
+				take = chunk
+			}
+			if atomic.CompareAndSwapInt64(&scheduler.next, claimed, claimed+int64(take)) {
+				start := int(claimed) + 1
+				return fileRange{start: start, end: minInt(start+take-1, size)}, true
+			}
+		}
+	}
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+/*
+RunLoop runs the files as a parallel loop over the file indices, scheduled the way OpenMP
+schedules a loop, see loopScheduler. Every worker parses the files of the chunks it gets into
+its own records, which are merged once it runs out of chunks.
+*/
+func RunLoop(args *utils.Arguments, size int, numThreads int, opts *Options) *utils.Result {
+	schedule, _ := parseSchedule(opts.Schedule)
+	if opts.Schedule == "" {
+		schedule = loopSchedule{kind: "static"}
+	}
+	scheduler := &loopScheduler{schedule: schedule, size: size, numThreads: numThreads}
+	recorder := opts.newRecorder("loop", numThreads)
+	merger := opts.newMerger(numThreads, recorder)
+
+	var group sync.WaitGroup
+	for id := 0; id < numThreads; id++ {
+		group.Add(1)
+		go func(id int) {
+			defer group.Done()
+			workerRecords := make(map[string][]int)
+			for k := 0; ; k++ {
+				files, ok := scheduler.chunk(id, k)
+				if !ok {
+					break
+				}
+				for i := files.start; i <= files.end; i++ {
+					fileNum := utils.GetFileNum(i)
+					parseStart := recorder.Now()
+					fileRecords := utils.ParseFile(args, fileNum)
+					recorder.Record(id, trace.Parse, fileNum, parseStart)
+					utils.MergeRecords(workerRecords, fileRecords)
+				}
+			}
+			merger.Merge(id, 0, workerRecords)
+		}(id)
+	}
+	group.Wait()
+	opts.writeTrace(recorder)
+
+	return merger.Result()
+}
@@ -0,0 +1,114 @@
+package modes
+
+import (
+	"sync"
+	"testing"
+)
+
+// Workers taking chunks at once must be handed every index from 1 to size exactly once, whatever the schedule
+func TestLoopSchedulesHandOutEveryIndexOnce(t *testing.T) {
+	for _, value := range []string{"static", "static,1", "static,3", "static,50", "dynamic", "dynamic,4", "dynamic,50", "guided", "guided,2", "guided,7"} {
+		schedule, err := parseSchedule(value)
+		if err != nil {
+			t.Fatalf("%v: %v", value, err)
+		}
+		for _, test := range []struct{ size, numThreads int }{{500, 1}, {500, 7}, {37, 8}, {5, 12}, {1, 3}} {
+			scheduler := &loopScheduler{schedule: schedule, size: test.size, numThreads: test.numThreads}
+			counts := make([]int, test.size+2)
+			var mutex sync.Mutex
+			var group sync.WaitGroup
+			for id := 0; id < test.numThreads; id++ {
+				group.Add(1)
+				go func(id int) {
+					defer group.Done()
+					for k := 0; ; k++ {
+						files, ok := scheduler.chunk(id, k)
+						if !ok {
+							return
+						}
+						mutex.Lock()
+						for i := files.start; i <= files.end; i++ {
+							if i >= 0 && i < len(counts) {
+								counts[i]++
+							}
+						}
+						mutex.Unlock()
+					}
+				}(id)
+			}
+			group.Wait()
+			for i, count := range counts {
+				want := 1
+				if i == 0 || i > test.size {
+					want = 0
+				}
+				if count != want {
+					t.Errorf("%v, %v files, %v threads: index %v handed out %v times", value, test.size, test.numThreads, i, count)
+				}
+			}
+		}
+	}
+}
+
+// Guided chunks start at the remaining indices over the threads and shrink, never below the smallest chunk but for the tail
+func TestGuidedChunksShrink(t *testing.T) {
+	for _, test := range []struct{ size, numThreads, min int }{{1000, 4, 1}, {1000, 8, 5}, {100, 3, 7}, {10, 2, 4}} {
+		schedule := loopSchedule{kind: "guided", chunk: test.min}
+		scheduler := &loopScheduler{schedule: schedule, size: test.size, numThreads: test.numThreads}
+		previous, next := test.size+1, 1
+		for k := 0; ; k++ {
+			files, ok := scheduler.chunk(k%test.numThreads, k/test.numThreads)
+			if !ok {
+				break
+			}
+			length := files.end - files.start + 1
+			if files.start != next {
+				t.Fatalf("%+v: chunk %v starts at %v, want %v", test, k, files.start, next)
+			}
+			if length > previous {
+				t.Errorf("%+v: chunk %v of %v files is larger than the chunk of %v before it", test, k, length, previous)
+			}
+			if length < test.min && files.end != test.size {
+				t.Errorf("%+v: chunk %v of %v files is below the smallest chunk", test, k, length)
+			}
+			if k == 0 && length != maxInt(test.size/test.numThreads, test.min) {
+				t.Errorf("%+v: first chunk of %v files, want %v", test, length, maxInt(test.size/test.numThreads, test.min))
+			}
+			previous, next = length, files.end+1
+		}
+		if next != test.size+1 {
+			t.Errorf("%+v: chunks stop before index %v", test, next)
+		}
+	}
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func TestParseSchedule(t *testing.T) {
+	for value, want := range map[string]loopSchedule{
+		"static":    {kind: "static", chunk: 0},
+		"static,5":  {kind: "static", chunk: 5},
+		"dynamic":   {kind: "dynamic", chunk: 1},
+		"dynamic,4": {kind: "dynamic", chunk: 4},
+		"guided":    {kind: "guided", chunk: 1},
+		"guided,2":  {kind: "guided", chunk: 2},
+	} {
+		if got, err := parseSchedule(value); err != nil || got != want {
+			t.Errorf("parseSchedule(%q) = %+v, %v, want %+v", value, got, err, want)
+		}
+	}
+	for _, value := range []string{"", "auto", "Static", "static,", "dynamic,0", "guided,-2", "dynamic,x", "dynamic,4,2", ",4", "guided 2"} {
+		if _, err := parseSchedule(value); err == nil {
+			t.Errorf("parseSchedule(%q) accepted a malformed schedule", value)
+		}
+		opts := Options{Schedule: value}
+		if err := opts.Validate(); value != "" && err == nil {
+			t.Errorf("Validate accepted -schedule %q", value)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package modes
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"proj3/barrier"
+	"proj3/locks"
+	"proj3/merge"
+	"proj3/stealing"
+	"proj3/trace"
+	"strings"
+	"sync"
+)
+
+/*
+Options holds the command line switches that tune how the parallel modes run. They are shared
+by the normal run, verify and bench so every tuning knob can be checked and measured the same
+way. The zero value runs every mode the way it has always run.
+*/
+type Options struct {
+	Stats  bool   // print per-worker scheduler statistics to stderr
+	Trace  string // file to write a Chrome trace of the run to, empty for no tracing
+	Deque  string // work-stealing deque implementation: chaselev or bounded
+	Submit bool   // feed stealing tasks to the running workers instead of pre-filling their deques
+	Split  int    // split files with more lines than this into forked row-range tasks, 0 to never split
+	Victim string // how idle stealing workers choose a victim, see stealing.VictimPolicies
+	Steal  string // how many tasks a thief takes: one or half
+	Idle   string // what idle stealing workers do: spin, backoff or park
+	Lock   string // lock guarding the global records, see locks.Locks
+	Merge  string // how workers merge into the global records: global, sharded or tree
+	Shards int    // number of shards of the sharded merge
+
+	// goroutines per stage of the pipeline mode and the capacity of the channels between the
+	// stages, 0 to derive them from the thread count
+	Readers int
+	Parsers int
+	Mergers int
+	Buffer  int
+
+	Chunk    int    // files the pool mode's workers pull at a time, 0 for 1
+	Schedule string // how the loop mode schedules the files: static, dynamic or guided, with an optional chunk
+
+	Partition string // how static and stealing split the files between the threads: count, bytes or lpt
+
+	ChunkBytes int64 // split files larger than this into byte ranges parsed in parallel, 0 to never split
+
+	Superstep int    // files, or byte ranges, every bsp worker parses per superstep, 0 for 1
+	Barrier   string // barrier the bsp workers synchronize on, see barrier.Barriers
+	Staleness int    // supersteps an ssp worker may run ahead of the slowest one
+
+	Group int // workers per group of the hierarchical mode, 0 for a single group
+}
+
+// RegisterFlags defines a flag for every option on fs, storing the values into opts
+func (opts *Options) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&opts.Stats, "stats", false, "print per-worker scheduler statistics to stderr (stealing)")
+	fs.StringVar(&opts.Trace, "trace", "", "write a Chrome trace_event JSON timeline of the run to this file")
+	fs.StringVar(&opts.Deque, "deque", "chaselev", "work-stealing deque: 'chaselev' (growable) or 'bounded' (at most 998 tasks per worker)")
+	fs.BoolVar(&opts.Submit, "submit", false, "stealing: submit the tasks to the already running workers instead of pre-filling their deques")
+	fs.IntVar(&opts.Split, "split", 0, "stealing: split files with more lines than this into row ranges forked as subtasks (0 = never)")
+	fs.StringVar(&opts.Victim, "victim", "random", fmt.Sprintf("stealing: victim selection policy, one of %v", strings.Join(stealing.VictimPolicyNames(), ", ")))
+	fs.StringVar(&opts.Steal, "steal", "one", "stealing: tasks taken per steal, 'one' or 'half' of the victim's deque")
+	fs.StringVar(&opts.Lock, "lock", "ttas", fmt.Sprintf("static, stealing, hierarchical: lock guarding the global records, one of %v", strings.Join(locks.Names(), ", ")))
+	fs.StringVar(&opts.Merge, "merge", "global", "how workers merge their records: 'global' (one map behind one lock), 'sharded' (hash-partitioned maps with a lock each) or 'tree' (per-worker maps reduced pairwise in parallel at the end)")
+	fs.IntVar(&opts.Shards, "shards", 64, "number of shards of the sharded merge")
+	fs.IntVar(&opts.Readers, "readers", 0, "pipeline: goroutines reading files (0 = threads)")
+	fs.IntVar(&opts.Parsers, "parsers", 0, "pipeline: goroutines parsing what was read (0 = threads)")
+	fs.IntVar(&opts.Mergers, "mergers", 0, "pipeline: goroutines merging the parsed records (0 = 1)")
+	fs.IntVar(&opts.Buffer, "buffer", 0, "pipeline: files each stage may be ahead of the next (0 = threads)")
+	fs.IntVar(&opts.Chunk, "chunk", 1, "pool: files a worker pulls from the channel at a time")
+	fs.StringVar(&opts.Schedule, "schedule", "static", "loop: 'static[,N]', 'dynamic[,N]' or 'guided[,N]' scheduling of the files, N being the chunk size (the smallest for guided)")
+	fs.StringVar(&opts.Partition, "partition", "count", "static, stealing: split the files between the threads by 'count', into consecutive groups of about equal 'bytes', or by 'lpt' (largest file first to the least loaded thread)")
+	fs.Int64Var(&opts.ChunkBytes, "chunk-bytes", 0, "pool, stealing, bsp, ssp: split files larger than this many bytes into ranges of whole lines parsed in parallel (0 = never)")
+	fs.IntVar(&opts.Superstep, "superstep", 1, "bsp, ssp: files (or byte ranges under -chunk-bytes) every worker parses per superstep")
+	fs.StringVar(&opts.Barrier, "barrier", "central", fmt.Sprintf("bsp: barrier the workers synchronize on, one of %v", strings.Join(barrier.Names(), ", ")))
+	fs.IntVar(&opts.Staleness, "staleness", 1, "ssp: supersteps a worker may run ahead of the slowest one (0 = bsp)")
+	fs.IntVar(&opts.Group, "group", 8, "hierarchical: workers per group, which merge and steal among themselves first (0 = all threads)")
+	fs.StringVar(&opts.Idle, "idle", "spin", "stealing: what idle workers do, 'spin' (yield), 'backoff' (sleep longer and longer) or 'park' (back off, then sleep until woken)")
+}
+
+// Validate reports the first option holding a value no mode understands
+func (opts *Options) Validate() error {
+	if opts.Deque != "" && opts.Deque != "chaselev" && opts.Deque != "bounded" {
+		return fmt.Errorf("unknown deque %q", opts.Deque)
+	}
+	if opts.Split < 0 {
+		return fmt.Errorf("split must not be negative")
+	}
+	if opts.Victim != "" {
+		if _, err := stealing.LookupVictimPolicy(opts.Victim); err != nil {
+			return err
+		}
+	}
+	if opts.Steal != "" {
+		if _, err := stealing.ParseStealAmount(opts.Steal); err != nil {
+			return err
+		}
+	}
+	if opts.Idle != "" {
+		if _, err := stealing.ParseIdlePolicy(opts.Idle); err != nil {
+			return err
+		}
+	}
+	if opts.Merge != "" && opts.Merge != "global" && opts.Merge != "sharded" && opts.Merge != "tree" {
+		return fmt.Errorf("unknown merge %q, want global, sharded or tree", opts.Merge)
+	}
+	if opts.Merge == "sharded" && opts.Shards < 1 {
+		return fmt.Errorf("shards must be at least 1")
+	}
+	if opts.Readers < 0 || opts.Parsers < 0 || opts.Mergers < 0 || opts.Buffer < 0 {
+		return fmt.Errorf("pipeline stage sizes must not be negative")
+	}
+	if opts.Chunk < 0 {
+		return fmt.Errorf("chunk must not be negative")
+	}
+	if opts.Superstep < 0 {
+		return fmt.Errorf("superstep must not be negative")
+	}
+	if opts.Staleness < 0 {
+		return fmt.Errorf("staleness must not be negative")
+	}
+	if opts.Group < 0 {
+		return fmt.Errorf("group must not be negative")
+	}
+	if opts.ChunkBytes < 0 {
+		return fmt.Errorf("chunk-bytes must not be negative")
+	}
+	if opts.ChunkBytes > 0 && opts.Split > 0 {
+		return fmt.Errorf("split and chunk-bytes both split files, set only one of them")
+	}
+	if opts.Schedule != "" {
+		if _, err := parseSchedule(opts.Schedule); err != nil {
+			return err
+		}
+	}
+	if err := validatePartition(opts.Partition); err != nil {
+		return err
+	}
+	if opts.Lock != "" {
+		if _, err := locks.Lookup(opts.Lock); err != nil {
+			return err
+		}
+	}
+	if opts.Barrier != "" {
+		if _, err := barrier.Lookup(opts.Barrier); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockConstructor returns the constructor of the lock selected for the global records, TTAS by default
+func (opts *Options) lockConstructor() func() sync.Locker {
+	if opts.Lock == "" {
+		return locks.NewTTAS
+	}
+	newLock, _ := locks.Lookup(opts.Lock)
+	return newLock
+}
+
+// barrierConstructor returns the constructor of the barrier selected for bsp, the central counter by default
+func (opts *Options) barrierConstructor() func(numWorkers int) barrier.Barrier {
+	if opts.Barrier == "" {
+		return barrier.NewCentral
+	}
+	newBarrier, _ := barrier.Lookup(opts.Barrier)
+	return newBarrier
+}
+
+/*
+newMerger returns the merger selected for the global records of numWorkers workers, guarded by
+the selected lock unless it is a tree
+*/
+func (opts *Options) newMerger(numWorkers int, recorder *trace.Recorder) merge.Merger {
+	switch opts.Merge {
+	case "sharded":
+		return merge.NewSharded(opts.Shards, opts.lockConstructor(), recorder)
+	case "tree":
+		return merge.NewTree(numWorkers, recorder)
+	}
+	return merge.NewLocked(opts.lockConstructor()(), recorder)
+}
+
+// concurrentMerge reports whether the selected merger lets workers merge without queueing for one lock
+func (opts *Options) concurrentMerge() bool {
+	return opts.Merge == "sharded" || opts.Merge == "tree"
+}
+
+// newRecorder returns a trace recorder for the run if tracing was requested, nil otherwise
+func (opts *Options) newRecorder(mode string, numWorkers int) *trace.Recorder {
+	if opts.Trace == "" {
+		return nil
+	}
+	return trace.NewRecorder(mode, numWorkers)
+}
+
+// writeTrace writes out the recorded trace, if any. Failing to write it does not fail the run
+func (opts *Options) writeTrace(recorder *trace.Recorder) {
+	if recorder == nil {
+		return
+	}
+	if err := recorder.WriteFile(opts.Trace); err != nil {
+		fmt.Fprintf(os.Stderr, "writing trace: %v\n", err)
+	}
+}
@@ -0,0 +1,121 @@
+package modes
+
+import (
+	"fmt"
+	"proj3/utils"
+	"sort"
+)
+
+// validatePartition checks the name of a partitioning, see partitionFiles
+func validatePartition(method string) error {
+	switch method {
+	case "", "count", "bytes", "lpt":
+		return nil
+	}
+	return fmt.Errorf("unknown partition %q, want count, bytes or lpt", method)
+}
+
+/*
+partitionFiles splits the file indices 1 to size into numThreads groups:
+
+  - count gives every group size/numThreads consecutive files, and the last group the rest,
+    the way static and stealing always split the work.
+  - bytes gives every group consecutive files too, but cuts where the groups get about the same
+    number of bytes: a file goes to the group its middle byte falls into.
+  - lpt (longest processing time first) hands out the files from the largest to the smallest,
+    each to the group with the fewest bytes so far. The groups are not consecutive, and list
+    their files from the largest to the smallest.
+
+bytes and lpt stat every file once and also return the size of the file behind every index,
+which is nil for count. They fall back to count if there are no bytes to balance.
+
+Groups may be empty: under count when there are more threads than files, and under bytes and
+lpt when a few files outweigh the rest. Their workers start with an empty deque and steal.
+*/
+func partitionFiles(size int, numThreads int, method string) ([][]int, []int64) {
+	groups := make([][]int, numThreads)
+	if method == "bytes" || method == "lpt" {
+		weights, total := fileWeights(size)
+		if total > 0 && method == "bytes" {
+			var before int64
+			for i := 1; i <= size; i++ {
+				weight := weights[i]
+				group := int((before + weight/2) * int64(numThreads) / total)
+				if group >= numThreads {
+					group = numThreads - 1
+				}
+				groups[group] = append(groups[group], i)
+				before += weight
+			}
+			return groups, weights
+		}
+		if total > 0 {
+			largestFirst := largestFirst(size, weights)
+			loads := make([]int64, numThreads)
+			for _, i := range largestFirst {
+				lightest := 0
+				for group := range loads {
+					if loads[group] < loads[lightest] {
+						lightest = group
+					}
+				}
+				groups[lightest] = append(groups[lightest], i)
+				loads[lightest] += weights[i]
+			}
+			return groups, weights
+		}
+	}
+
+	workAmount := size / numThreads // static distribution
+	remWork := size % numThreads    // last thread does extra work
+	for i := 0; i < numThreads; i++ {
+		startPt := i*workAmount + 1
+		endPt := (i + 1) * workAmount
+		if i == numThreads-1 {
+			endPt += remWork
+		}
+		for j := startPt; j <= endPt; j++ {
+			groups[i] = append(groups[i], j)
+		}
+	}
+	return groups, nil
+}
+
+// fileWeights returns the size in bytes of the file behind every index from 1 to size, and their sum
+func fileWeights(size int) ([]int64, int64) {
+	sizes := make(map[int]int64) // indices past NUM_FILES reuse the same files, stat each once
+	weights := make([]int64, size+1)
+	var total int64
+	for i := 1; i <= size; i++ {
+		fileNum := utils.GetFileNum(i)
+		weight, ok := sizes[fileNum]
+		if !ok {
+			weight = utils.FileSize(fileNum)
+			sizes[fileNum] = weight
+		}
+		weights[i] = weight
+		total += weight
+	}
+	return weights, total
+}
+
+// largestFirst returns the indices 1 to size ordered from the largest file to the smallest
+func largestFirst(size int, weights []int64) []int {
+	indices := make([]int, size)
+	for i := range indices {
+		indices[i] = i + 1
+	}
+	sort.SliceStable(indices, func(a, b int) bool {
+		return weights[indices[a]] > weights[indices[b]]
+	})
+	return indices
+}
+
+// sortLargestFirst orders the files of each group from the largest to the smallest
+func sortLargestFirst(groups [][]int, weights []int64) {
+	for _, group := range groups {
+		sort.SliceStable(group, func(a, b int) bool {
+			return weights[group[a]] > weights[group[b]]
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package modes
+
+import (
+	"proj3/datatest"
+	"strings"
+	"testing"
+)
+
+// withSizedFiles runs the test next to a data directory whose file i holds lines(i) lines
+func withSizedFiles(t *testing.T, size int, lines func(i int) int) {
+	t.Helper()
+	contents := make(map[int]string)
+	for i := 1; i <= size; i++ {
+		contents[i] = strings.Repeat("a,b,c\n", lines(i))
+	}
+	datatest.WithFiles(t, contents)
+}
+
+// Every file must land in exactly one group, even when there are more threads than files and groups are left empty
+func TestPartitionFilesCoversEveryFileOnce(t *testing.T) {
+	withSizedFiles(t, 500, func(i int) int {
+		if i%10 == 0 {
+			return 1000 // one in ten of them large, so that bytes and lpt have sizes to balance
+		}
+		return 10
+	})
+	for _, method := range []string{"count", "bytes", "lpt"} {
+		for _, test := range []struct{ size, numThreads int }{{500, 1}, {500, 7}, {60, 70}, {1, 4}, {30, 12}} {
+			groups, weights := partitionFiles(test.size, test.numThreads, method)
+			if len(groups) != test.numThreads {
+				t.Fatalf("%v %+v: %v groups", method, test, len(groups))
+			}
+			if method != "count" && weights == nil {
+				t.Fatalf("%v %+v: fell back to count", method, test)
+			}
+			seen := make([]int, test.size+1)
+			for _, group := range groups {
+				for _, i := range group {
+					if i < 1 || i > test.size {
+						t.Fatalf("%v %+v: file index %v out of range", method, test, i)
+					}
+					seen[i]++
+				}
+			}
+			for i := 1; i <= test.size; i++ {
+				if seen[i] != 1 {
+					t.Fatalf("%v %+v: file %v is in %v groups", method, test, i, seen[i])
+				}
+			}
+		}
+	}
+}
+
+/*
+With skewed file sizes, bytes and lpt must balance the bytes of the groups better than count.
+lpt must stay within 4/3 of the optimum, which is at least the largest file and at least an
+even share of all the bytes. bytes cuts consecutive files, so a group may overshoot an even
+share by at most one file.
+*/
+func TestPartitionFilesBalancesBytes(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		size       int
+		numThreads int
+		lines      func(i int) int
+	}{
+		{"large first", 60, 4, func(i int) int {
+			if i <= 8 {
+				return 200
+			}
+			return 10
+		}},
+		{"growing", 100, 7, func(i int) int { return i * i }},
+		{"one in ten", 500, 6, func(i int) int {
+			if i%10 == 0 {
+				return 1000
+			}
+			return 10
+		}},
+		{"spikes", 45, 8, func(i int) int { return 1 + i%9*i%13*20 }},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			withSizedFiles(t, test.size, test.lines)
+			maxLoad := make(map[string]int64)
+			var weights []int64
+			for _, method := range []string{"count", "bytes", "lpt"} {
+				var groups [][]int
+				groups, weights = partitionFiles(test.size, test.numThreads, method)
+				if method == "count" {
+					weights, _ = fileWeights(test.size)
+				}
+				for _, group := range groups {
+					var load int64
+					for _, i := range group {
+						load += weights[i]
+					}
+					if load > maxLoad[method] {
+						maxLoad[method] = load
+					}
+				}
+			}
+
+			var total, largest int64
+			for _, weight := range weights {
+				total += weight
+				if weight > largest {
+					largest = weight
+				}
+			}
+			optimum := (total + int64(test.numThreads) - 1) / int64(test.numThreads)
+			if largest > optimum {
+				optimum = largest
+			}
+			if maxLoad["lpt"]*3 > optimum*4 {
+				t.Errorf("lpt: largest group has %v bytes, more than 4/3 of at least %v", maxLoad["lpt"], optimum)
+			}
+			if share := (total + int64(test.numThreads) - 1) / int64(test.numThreads); maxLoad["bytes"] > share+largest {
+				t.Errorf("bytes: largest group has %v bytes, more than a share of %v and a file of %v", maxLoad["bytes"], share, largest)
+			}
+			for _, method := range []string{"bytes", "lpt"} {
+				if maxLoad[method] >= maxLoad["count"] {
+					t.Errorf("%v: largest group has %v bytes, no fewer than the %v of count", method, maxLoad[method], maxLoad["count"])
+				}
+			}
+		})
+	}
+}
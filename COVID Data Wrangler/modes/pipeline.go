@@ -0,0 +1,137 @@
+package modes
+
+import (
+	"proj3/trace"
+	"proj3/utils"
+	"sync"
+)
+
+// rawFile is the contents of a file as read by a reader, on its way to a parser
+type rawFile struct {
+	fileNum int
+	data    []byte
+}
+
+// parsedFile is the records of a file as built by a parser, on its way to a merger
+type parsedFile struct {
+	fileNum int
+	records map[string][]int
+}
+
+// pipelineSizes is the number of goroutines of each stage and the capacity of the channels between them
+type pipelineSizes struct {
+	readers int
+	parsers int
+	mergers int
+	buffer  int
+}
+
+// newPipelineSizes takes the sizes set in the options, and gives the ones left at 0 a default based on numThreads
+func newPipelineSizes(numThreads int, opts *Options) pipelineSizes {
+	sizes := pipelineSizes{readers: opts.Readers, parsers: opts.Parsers, mergers: opts.Mergers, buffer: opts.Buffer}
+	if sizes.readers == 0 {
+		sizes.readers = numThreads
+	}
+	if sizes.parsers == 0 {
+		sizes.parsers = numThreads
+	}
+	if sizes.mergers == 0 {
+		sizes.mergers = 1
+	}
+	if sizes.buffer == 0 {
+		sizes.buffer = numThreads
+	}
+	return sizes
+}
+
+/*
+RunPipeline separates reading the files from parsing them and merging the records, so that
+waiting for a slow file system overlaps with parsing instead of leaving cores idle:
+
+	file numbers -> readers -> raw bytes -> parsers -> records -> mergers
+
+Every stage has its own number of goroutines, and the channels between them are bounded, so
+a fast stage blocks once it is buffer files ahead of the next stage instead of piling up
+files in memory. Each stage closes its output channel once all of its goroutines are done,
+which lets the next stage drain what is left and finish in turn.
+
+In a trace the mergers come first, then the readers, then the parsers.
+*/
+func RunPipeline(args *utils.Arguments, size int, numThreads int, opts *Options) *utils.Result {
+	sizes := newPipelineSizes(numThreads, opts)
+	recorder := opts.newRecorder("pipeline", sizes.mergers+sizes.readers+sizes.parsers)
+	merger := opts.newMerger(sizes.mergers, recorder)
+	failed := &failures{} // files that could not be read or parsed, left out of the later stages
+
+	fileNums := make(chan int, sizes.buffer)
+	raw := make(chan rawFile, sizes.buffer)
+	parsed := make(chan parsedFile, sizes.buffer)
+
+	go func() {
+		for i := 1; i <= size; i++ {
+			fileNums <- utils.GetFileNum(i)
+		}
+		close(fileNums)
+	}()
+
+	var readers sync.WaitGroup
+	for i := 0; i < sizes.readers; i++ {
+		readers.Add(1)
+		go func(id int) {
+			defer readers.Done()
+			for fileNum := range fileNums {
+				readStart := recorder.Now()
+				data, err := utils.ReadBytes(fileNum)
+				recorder.Record(id, trace.Read, fileNum, readStart)
+				if err != nil {
+					failed.fail(fileNum, err)
+					continue
+				}
+				raw <- rawFile{fileNum: fileNum, data: data}
+			}
+		}(sizes.mergers + i)
+	}
+	go func() {
+		readers.Wait()
+		close(raw)
+	}()
+
+	var parsers sync.WaitGroup
+	for i := 0; i < sizes.parsers; i++ {
+		parsers.Add(1)
+		go func(id int) {
+			defer parsers.Done()
+			for file := range raw {
+				parseStart := recorder.Now()
+				lines, err := utils.SplitCSV(file.data)
+				records := utils.ParseLines(args, lines)
+				recorder.Record(id, trace.Parse, file.fileNum, parseStart)
+				if err != nil {
+					failed.fail(file.fileNum, err)
+					continue
+				}
+				parsed <- parsedFile{fileNum: file.fileNum, records: records}
+			}
+		}(sizes.mergers + sizes.readers + i)
+	}
+	go func() {
+		parsers.Wait()
+		close(parsed)
+	}()
+
+	var mergers sync.WaitGroup
+	for i := 0; i < sizes.mergers; i++ {
+		mergers.Add(1)
+		go func(id int) {
+			defer mergers.Done()
+			for file := range parsed {
+				merger.Merge(id, file.fileNum, file.records)
+			}
+		}(i)
+	}
+	mergers.Wait()
+	failed.report()
+	opts.writeTrace(recorder)
+
+	return merger.Result()
+}
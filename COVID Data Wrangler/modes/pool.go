@@ -0,0 +1,110 @@
+package modes
+
+import (
+	"proj3/trace"
+	"proj3/utils"
+	"sync"
+)
+
+// fileRange is a chunk of consecutive file indices, from start to end inclusive
+type fileRange struct {
+	start int
+	end   int
+}
+
+// poolWork is what a pool worker pulls at a time: a chunk of whole files, or one byte range of a large file
+type poolWork struct {
+	files     fileRange
+	byteRange *utils.ByteRange
+}
+
+/*
+sendPoolWork sends the file indices 1 to size in chunks of chunk files. With chunkBytes, files
+larger than that are split into byte ranges that are sent one by one instead, so that several
+workers parse them. The chunk around such a file is cut short before it and resumes after it.
+A file that cannot be split is recorded in failed and left out the same way.
+*/
+func sendPoolWork(work chan<- poolWork, size int, chunk int, chunkBytes int64, failed *failures) {
+	for start := 1; start <= size; start += chunk {
+		end := start + chunk - 1
+		if end > size {
+			end = size
+		}
+		next := start // first index not sent yet
+		for i := start; i <= end && chunkBytes > 0; i++ {
+			fileNum := utils.GetFileNum(i)
+			ranges, err := utils.SplitFile(fileNum, chunkBytes)
+			if err != nil {
+				failed.fail(fileNum, err)
+			} else if len(ranges) == 1 {
+				continue
+			}
+			if next < i {
+				work <- poolWork{files: fileRange{start: next, end: i - 1}}
+			}
+			for j := range ranges {
+				work <- poolWork{byteRange: &ranges[j]}
+			}
+			next = i + 1
+		}
+		if next <= end {
+			work <- poolWork{files: fileRange{start: next, end: end}}
+		}
+	}
+	close(work)
+}
+
+/*
+RunPool is the plain Go worker pool: the file indices are sent through a channel in chunks
+of opts.Chunk, and numThreads goroutines pull the next chunk whenever they are done with the
+last one. Like static, every worker deduplicates into its own records and merges them once
+at the end, but the work is handed out dynamically, so a worker that drew slow files simply
+pulls fewer chunks. It is the baseline that the custom deques of stealing have to beat.
+*/
+func RunPool(args *utils.Arguments, size int, numThreads int, opts *Options) *utils.Result {
+	chunk := opts.Chunk
+	if chunk == 0 {
+		chunk = 1
+	}
+	recorder := opts.newRecorder("pool", numThreads)
+	merger := opts.newMerger(numThreads, recorder)
+	failed := &failures{} // files that could not be split and byte ranges that could not be read
+
+	work := make(chan poolWork, numThreads)
+	go sendPoolWork(work, size, chunk, opts.ChunkBytes, failed)
+
+	var group sync.WaitGroup
+	for id := 0; id < numThreads; id++ {
+		group.Add(1)
+		go func(id int) {
+			defer group.Done()
+			workerRecords := make(map[string][]int)
+			for pulled := range work {
+				if pulled.byteRange != nil {
+					parseStart := recorder.Now()
+					rangeRecords, err := utils.ParseRange(args, *pulled.byteRange)
+					recorder.Record(id, trace.Parse, pulled.byteRange.FileNum, parseStart)
+					if err != nil {
+						failed.fail(pulled.byteRange.FileNum, err)
+						continue
+					}
+					utils.MergeRecords(workerRecords, rangeRecords)
+					continue
+				}
+				for i := pulled.files.start; i <= pulled.files.end; i++ {
+					fileNum := utils.GetFileNum(i)
+					parseStart := recorder.Now()
+					fileRecords := utils.ParseFile(args, fileNum)
+					recorder.Record(id, trace.Parse, fileNum, parseStart)
+					utils.MergeRecords(workerRecords, fileRecords)
+				}
+			}
+			merger.Merge(id, 0, workerRecords)
+		}(id)
+	}
+	group.Wait()
+	failed.report()
+	opts.writeTrace(recorder)
+
+	return merger.Result()
+}
@@ -0,0 +1,129 @@
+package modes
+
+import (
+	"fmt"
+	"os"
+	"proj3/bsp"
+	"proj3/merge"
+	"proj3/trace"
+	"proj3/utils"
+	"reflect"
+)
+
+/*
+recordBatch is the message of the pregel mode: records sent to the worker that owns their
+keys, and how many records with conflicting values were dropped when batches were combined
+*/
+type recordBatch struct {
+	records   map[string][]int
+	conflicts int
+}
+
+// combineBatches merges from into into, into's records winning the way MergeRecords does
+func combineBatches(into recordBatch, from recordBatch) recordBatch {
+	into.conflicts += from.conflicts
+	for key, val := range from.records {
+		if existing, contains := into.records[key]; contains {
+			if !reflect.DeepEqual(existing, val) {
+				into.conflicts++
+			}
+			continue
+		} // skip duplicate
+		into.records[key] = val
+	}
+	return into
+}
+
+// pregelWorker is the state a worker of the pregel mode keeps across supersteps
+type pregelWorker struct {
+	records *merge.Partial // the records of the keys the worker owns
+}
+
+/*
+RunPregel expresses the wrangler as a program for the bsp package, in two phases that overlap
+from one superstep to the next. Each key is owned by one worker, picked by its hash.
+
+  - In superstep s, worker w parses file s*threads+w+1, if there is one, and sends every record
+    to the owner of its key. The batches for the same owner are combined on the way.
+  - In the next superstep the owner adds the records it received to the ones it owns, which
+    removes the duplicates, and checks every duplicate for the same values. A record whose
+    values differ from those of the record already owned is counted as a conflict.
+
+A worker votes to halt once it has parsed its last file, and is only woken again to take in
+records. The program ends when every worker has halted and no records are in flight. The keys
+owned by different workers are disjoint, so the result is simply their union.
+
+With -stats, the number of supersteps and of conflicting records is printed to stderr.
+*/
+func RunPregel(args *utils.Arguments, size int, numThreads int, opts *Options) *utils.Result {
+	recorder := opts.newRecorder("pregel", numThreads)
+	workers := make([]pregelWorker, numThreads)
+	for i := range workers {
+		workers[i] = pregelWorker{records: merge.NewPartial()}
+	}
+
+	program := bsp.Program[recordBatch]{
+		Combiner:    combineBatches,
+		Aggregators: map[string]bsp.Aggregator{"conflicts": bsp.Sum(true)},
+		Compute: func(worker *bsp.Worker[recordBatch]) {
+			state := &workers[worker.ID]
+
+			// take in the records owned by this worker
+			mergeStart := recorder.Now()
+			for _, batch := range worker.Messages() {
+				conflicts := batch.conflicts
+				for key, val := range batch.records {
+					if existing, contains := state.records.Records[key]; contains && !reflect.DeepEqual(existing, val) {
+						conflicts++
+					}
+				}
+				state.records.Add(batch.records)
+				worker.Aggregate("conflicts", conflicts)
+			}
+			if len(worker.Messages()) > 0 {
+				recorder.Record(worker.ID, trace.Merge, 0, mergeStart)
+			}
+
+			// parse the file of this superstep and send its records to their owners
+			fileIdx := worker.Superstep()*numThreads + worker.ID + 1
+			if fileIdx <= size {
+				fileNum := utils.GetFileNum(fileIdx)
+				parseStart := recorder.Now()
+				fileRecords := utils.ParseFile(args, fileNum)
+				recorder.Record(worker.ID, trace.Parse, fileNum, parseStart)
+				batches := make([]map[string][]int, numThreads)
+				for key, val := range fileRecords {
+					owner := int(merge.KeyHash(key) % uint32(numThreads))
+					if batches[owner] == nil {
+						batches[owner] = make(map[string][]int)
+					}
+					batches[owner][key] = val
+				}
+				for owner, records := range batches {
+					if records != nil {
+						worker.Send(owner, recordBatch{records: records})
+					}
+				}
+			}
+			if fileIdx+numThreads > size {
+				worker.VoteToHalt()
+			}
+		},
+	}
+	stats := bsp.Run(numThreads, program)
+	opts.writeTrace(recorder)
+	if opts.Stats {
+		fmt.Fprintf(os.Stderr, "supersteps: %v, conflicting records: %v\n", stats.Supersteps, stats.Aggregated["conflicts"])
+	}
+
+	result := utils.NewResult()
+	for _, state := range workers {
+		for key, val := range state.records.Records {
+			result.Records[key] = val
+		}
+		result.TotalCases += state.records.Cases
+		result.TotalTests += state.records.Tests
+		result.TotalDeaths += state.records.Deaths
+	}
+	return result
+}
@@ -0,0 +1,52 @@
+package modes
+
+import (
+	"proj3/utils"
+)
+
+// Mode describes one implementation of the wrangler that can be selected by name
+// from the command line or exercised by the verify command
+type Mode struct {
+	Name       string
+	MinThreads int // smallest thread count the mode can run with
+	Run        func(args *utils.Arguments, size int, numThreads int, opts *Options) *utils.Result
+}
+
+/*
+The registry lists every mode in the order they are reported. Sequential is registered
+as well so that tools comparing modes have a reference implementation to diff against,
+its thread count is simply ignored.
+*/
+var registry = []Mode{
+	{Name: "sequential", MinThreads: 0, Run: func(args *utils.Arguments, size int, numThreads int, opts *Options) *utils.Result {
+		return RunSequential(args, size)
+	}},
+	{Name: "static", MinThreads: 1, Run: RunStatic},
+	{Name: "stealing", MinThreads: 1, Run: RunStealing},
+	{Name: "bsp", MinThreads: 1, Run: func(args *utils.Arguments, size int, numThreads int, opts *Options) *utils.Result {
+		return RunBSP(numThreads, args, size, opts)
+	}},
+	{Name: "pipeline", MinThreads: 1, Run: RunPipeline},
+	{Name: "pool", MinThreads: 1, Run: RunPool},
+	{Name: "loop", MinThreads: 1, Run: RunLoop},
+	{Name: "pregel", MinThreads: 1, Run: RunPregel},
+	{Name: "ssp", MinThreads: 1, Run: RunSSP},
+	{Name: "hierarchical", MinThreads: 1, Run: RunHierarchical},
+}
+
+// Modes returns all registered modes
+func Modes() []Mode {
+	modes := make([]Mode, len(registry))
+	copy(modes, registry)
+	return modes
+}
+
+// Lookup returns the mode registered under name
+func Lookup(name string) (Mode, bool) {
+	for _, mode := range registry {
+		if mode.Name == name {
+			return mode, true
+		}
+	}
+	return Mode{}, false
+}
@@ -0,0 +1,114 @@
+package modes
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"proj3/datatest"
+	"proj3/utils"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Every registered mode must give the records and totals of sequential, whatever the threads and options
+func TestModesMatchSequential(t *testing.T) {
+	const size = 40
+	datatest.WithFiles(t, datatest.Covid(size, 60, 3))
+	queries := []utils.Arguments{{Zipcode: "60601", Month: 3, Year: 2020}, {Zipcode: "60607", Month: 11, Year: 2020}, {Zipcode: "60604", Month: 2, Year: 2021},
+		// together, these two see a record of every file, so a mode losing one file fails
+		{Zipcode: datatest.OwnZipcode, Month: 3, Year: 2020}, {Zipcode: datatest.OwnZipcode, Month: 4, Year: 2020}}
+	options := map[string]Options{
+		"defaults": {},
+		"byte ranges": {ChunkBytes: 2000, Partition: "lpt", Merge: "sharded", Shards: 4, Chunk: 3, Schedule: "guided,2",
+			Superstep: 2, Staleness: 2, Group: 2, Barrier: "dissemination", Steal: "half", Idle: "park"},
+		"row ranges": {Split: 20, Partition: "bytes", Merge: "tree", Submit: true, Schedule: "dynamic,4", Readers: 2, Parsers: 3,
+			Mergers: 2, Buffer: 1, Barrier: "tournament", Staleness: 0, Victim: "p2c", Lock: "mcs"},
+	}
+
+	for _, args := range queries {
+		args := args
+		want := RunSequential(&args, size)
+		if len(want.Records) == 0 {
+			t.Fatalf("query %+v matched no record of the generated data", args)
+		}
+		for name, opts := range options {
+			opts := opts
+			if err := opts.Validate(); err != nil {
+				t.Fatalf("%v: %v", name, err)
+			}
+			for _, mode := range Modes() {
+				for _, numThreads := range []int{1, 3, 8} {
+					got := mode.Run(&args, size, numThreads, &opts)
+					if got.TotalCases != want.TotalCases || got.TotalTests != want.TotalTests || got.TotalDeaths != want.TotalDeaths ||
+						!reflect.DeepEqual(got.Records, want.Records) {
+						t.Errorf("%v with %v threads and %v, query %+v: %v in %v records, want %v in %v",
+							mode.Name, numThreads, name, args, got, len(got.Records), want, len(want.Records))
+					}
+				}
+			}
+		}
+	}
+}
+
+// captureStderr returns what run writes to stderr
+func captureStderr(t *testing.T, run func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = writer
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+	defer func() {
+		os.Stderr = stderr
+	}()
+	run()
+	writer.Close()
+	return <-output
+}
+
+/*
+Files that are missing must be left out of the result like sequential leaves them out, without
+a mode hanging on them, and the modes reading files on their own must report them as missing.
+*/
+func TestModesReportMissingFiles(t *testing.T) {
+	const size = 30
+	contents := datatest.Covid(size, 40, 5)
+	delete(contents, 7)
+	delete(contents, 23)
+	datatest.WithFiles(t, contents)
+	args := utils.Arguments{Zipcode: datatest.OwnZipcode, Month: 3, Year: 2020}
+	want := RunSequential(&args, size)
+	if len(want.Records) != size-2 {
+		t.Fatalf("sequential found %v records of the files' own, want %v", len(want.Records), size-2)
+	}
+
+	byteRanges := Options{ChunkBytes: 500}
+	rowRanges := Options{Split: 10}
+	for _, test := range []struct {
+		mode string
+		opts Options
+	}{
+		{"pipeline", Options{}}, {"pool", byteRanges}, {"stealing", byteRanges}, {"stealing", rowRanges},
+		{"hierarchical", byteRanges}, {"hierarchical", rowRanges}, {"bsp", byteRanges}, {"ssp", byteRanges},
+	} {
+		mode, _ := Lookup(test.mode)
+		opts := test.opts
+		var got *utils.Result
+		output := captureStderr(t, func() { got = mode.Run(&args, size, 4, &opts) })
+		if !reflect.DeepEqual(got.Records, want.Records) {
+			t.Errorf("%v with %+v: %v records, want %v", test.mode, test.opts, len(got.Records), len(want.Records))
+		}
+		for _, fileNum := range []int{7, 23} {
+			if !strings.Contains(output, fmt.Sprintf("records missing from the result: file %v:", fileNum)) {
+				t.Errorf("%v with %+v did not report file %v missing, wrote %q", test.mode, test.opts, fileNum, output)
+			}
+		}
+	}
+}
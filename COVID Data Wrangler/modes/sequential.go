@@ -1,22 +1,16 @@
 package modes
 
 import (
-	"fmt"
 	"proj3/utils"
 )
 
-func RunSequential(args *utils.Arguments, size int) {
-	totalCases := 0
-	totalTests := 0
-	totalDeaths := 0
-	allRecords := make(map[string]bool)
+func RunSequential(args *utils.Arguments, size int) *utils.Result {
+	result := utils.NewResult()
 
 	for i := 1; i <= size; i++ {
 		fileNum := utils.GetFileNum(i)
 		fileRecord := utils.ParseFile(args, fileNum)
-		utils.UpdateGlobal(fileRecord, allRecords, &totalCases, &totalTests, &totalDeaths)
+		utils.UpdateGlobal(fileRecord, result.Records, &result.TotalCases, &result.TotalTests, &result.TotalDeaths)
 	}
-	result := fmt.Sprintf("%v,%v,%v", totalCases, totalTests, totalDeaths)
-	fmt.Println(result)
-	return
+	return result
 }
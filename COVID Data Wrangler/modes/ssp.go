@@ -0,0 +1,248 @@
+package modes
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"proj3/merge"
+	"proj3/trace"
+	"proj3/utils"
+	"sync"
+	"time"
+)
+
+/*
+sspClock keeps track of the supersteps every worker has completed, so that a worker can wait
+until the slowest one is close enough behind it. Unlike a barrier, a worker that completes a
+superstep does not wait for anyone: it only waits before starting one that would take it too
+far ahead.
+*/
+type sspClock struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	clocks  []int // supersteps completed by every worker
+	slowest int   // fewest supersteps completed by any worker
+}
+
+func newSSPClock(numThreads int) *sspClock {
+	clock := &sspClock{clocks: make([]int, numThreads)}
+	clock.cond = sync.NewCond(&clock.mutex)
+	return clock
+}
+
+// advance records that worker id completed a superstep, waking up the workers waiting for it if it was the slowest
+func (clock *sspClock) advance(id int) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	clock.clocks[id]++
+	if clock.clocks[id]-1 != clock.slowest {
+		return
+	}
+	slowest := clock.clocks[id]
+	for _, completed := range clock.clocks {
+		if completed < slowest {
+			slowest = completed
+		}
+	}
+	if slowest != clock.slowest {
+		clock.slowest = slowest
+		clock.cond.Broadcast()
+	}
+}
+
+// await waits until every worker has completed at least steps supersteps
+func (clock *sspClock) await(steps int) {
+	clock.mutex.Lock()
+	defer clock.mutex.Unlock()
+	for clock.slowest < steps {
+		clock.cond.Wait()
+	}
+}
+
+/*
+sspRecord is a record together with the task it came from. Owners receive the records of
+different workers and supersteps in any order, and keep the one from the earliest task, which
+is the one sequential keeps.
+*/
+type sspRecord struct {
+	task int
+	val  []int
+}
+
+// sspWorker is how a worker of the ssp mode spent its run, only written by the worker itself
+type sspWorker struct {
+	owned   map[string]sspRecord // the records merged so far of the keys the worker owns
+	busy    time.Duration        // time spent parsing and merging
+	waiting time.Duration        // time spent waiting for the slowest worker
+}
+
+/*
+sspContext is the state shared by the workers of an ssp run. Like bsp, every key is owned by one
+worker, and the workers sort the records they parse by owner. Instead of being merged after a
+barrier, they are sent to the owners' inboxes right away, and every owner merges what it has
+received whenever it starts a superstep, so the global state is updated asynchronously.
+
+A worker may run up to staleness supersteps ahead of the slowest worker, and in turn the
+slowest is at most that many supersteps behind any worker. So no worker has more than
+2*staleness+2 batches in any owner's inbox that the owner has not taken out yet, which bounds
+the inboxes: sending never blocks.
+*/
+type sspContext struct {
+	numThreads int
+	bspTasks
+	staleness int
+	clock     *sspClock
+	inboxes   []chan map[string]sspRecord // batches of records sent to every owner
+	workers   []sspWorker
+	recorder  *trace.Recorder
+}
+
+func newSSPContext(args *utils.Arguments, size int, numThreads int, opts *Options) *sspContext {
+	ctx := &sspContext{numThreads: numThreads, staleness: opts.Staleness}
+	ctx.bspTasks = newBSPTasks(numThreads, args, size, opts)
+	ctx.clock = newSSPClock(numThreads)
+	ctx.inboxes = make([]chan map[string]sspRecord, numThreads)
+	ctx.workers = make([]sspWorker, numThreads)
+	for idx := 0; idx < numThreads; idx++ {
+		ctx.inboxes[idx] = make(chan map[string]sspRecord, numThreads*(2*opts.Staleness+2))
+		ctx.workers[idx].owned = make(map[string]sspRecord)
+	}
+	return ctx
+}
+
+// receive merges the batches in the inbox of worker idx, without waiting for more
+func (ctx *sspContext) receive(idx int) {
+	owned := ctx.workers[idx].owned
+	for {
+		select {
+		case batch := <-ctx.inboxes[idx]:
+			for key, record := range batch {
+				if old, contains := owned[key]; !contains || record.task < old.task {
+					owned[key] = record
+				}
+			}
+		default:
+			return
+		}
+	}
+}
+
+/*
+sspStep runs the tasks of worker idx in superstep step, and sends the records to their owners.
+The records of the worker's own keys are sent to its own inbox as well.
+*/
+func sspStep(idx int, step int, ctx *sspContext) {
+	outbox := make([]map[string]sspRecord, ctx.numThreads)
+	for owner := range outbox {
+		outbox[owner] = make(map[string]sspRecord)
+	}
+	first, last := ctx.stepTasks(idx, step, ctx.numThreads)
+	for taskIdx := first; taskIdx <= last; taskIdx++ {
+		parseStart := ctx.recorder.Now()
+		fileNum, records := ctx.parse(taskIdx)
+		ctx.recorder.Record(idx, trace.Parse, fileNum, parseStart)
+		for key, val := range records {
+			batch := outbox[int(merge.KeyHash(key)%uint32(ctx.numThreads))]
+			if _, contains := batch[key]; !contains {
+				batch[key] = sspRecord{task: taskIdx, val: val}
+			}
+		}
+	}
+	for owner, batch := range outbox {
+		if len(batch) > 0 {
+			ctx.inboxes[owner] <- batch
+		}
+	}
+}
+
+// waitForSlowest waits until the slowest worker has completed steps supersteps, accounting the time to worker idx
+func (ctx *sspContext) waitForSlowest(idx int, steps int) {
+	waitStart := time.Now()
+	traceStart := ctx.recorder.Now()
+	ctx.clock.await(steps)
+	ctx.recorder.Record(idx, trace.Barrier, 0, traceStart)
+	ctx.workers[idx].waiting += time.Since(waitStart)
+}
+
+func executeSSP(idx int, ctx *sspContext) {
+	worker := &ctx.workers[idx]
+	for step := 0; step < ctx.numSteps; step++ {
+		ctx.waitForSlowest(idx, step-ctx.staleness)
+
+		busyStart := time.Now()
+		mergeStart := ctx.recorder.Now()
+		ctx.receive(idx)
+		ctx.recorder.Record(idx, trace.Merge, 0, mergeStart)
+		sspStep(idx, step, ctx)
+		worker.busy += time.Since(busyStart)
+
+		ctx.clock.advance(idx)
+	}
+
+	// once everyone is done, every batch has been sent
+	ctx.waitForSlowest(idx, ctx.numSteps)
+	mergeStart := ctx.recorder.Now()
+	ctx.receive(idx)
+	ctx.recorder.Record(idx, trace.Merge, 0, mergeStart)
+}
+
+// writeSSPStats prints a table with the time every worker was busy and waited for the slowest one, followed by the totals
+func writeSSPStats(w io.Writer, workers []sspWorker) {
+	format := "%-8v %12v %12v %6v\n"
+	fmt.Fprintf(w, format, "worker", "busy", "waiting", "wait%")
+	total := sspWorker{}
+	for id, worker := range workers {
+		writeSSPStatsRow(w, format, id, worker)
+		total.busy += worker.busy
+		total.waiting += worker.waiting
+	}
+	writeSSPStatsRow(w, format, "total", total)
+}
+
+func writeSSPStatsRow(w io.Writer, format string, name interface{}, worker sspWorker) {
+	waitPercent := 0.0
+	if worker.busy+worker.waiting > 0 {
+		waitPercent = 100 * float64(worker.waiting) / float64(worker.busy+worker.waiting)
+	}
+	fmt.Fprintf(w, format, name, worker.busy.Round(time.Microsecond), worker.waiting.Round(time.Microsecond), fmt.Sprintf("%.1f", waitPercent))
+}
+
+/*
+RunSSP is bsp under stale synchronous parallel: instead of waiting for everyone at the end of
+every superstep, a worker only waits before starting superstep s until the slowest worker has
+completed superstep s - opts.Staleness - 1. A worker that drew small files can therefore run
+ahead and get on with the next ones while a large file is still being parsed, and the merged
+records catch up asynchronously, see sspContext. With a staleness of 0 every superstep waits for
+the one before to be completed by everyone, which is bsp.
+
+With -stats the time every worker was busy and waited is printed to stderr.
+*/
+func RunSSP(args *utils.Arguments, size int, numThreads int, opts *Options) *utils.Result {
+	ctx := newSSPContext(args, size, numThreads, opts)
+	ctx.recorder = opts.newRecorder("ssp", numThreads)
+	merger := opts.newMerger(numThreads, ctx.recorder)
+
+	var group sync.WaitGroup
+	for idx := 0; idx < numThreads; idx++ {
+		group.Add(1)
+		go func(idx int) {
+			defer group.Done()
+			executeSSP(idx, ctx)
+
+			// the owners hold disjoint keys, so they can hand them to any merger concurrently
+			records := make(map[string][]int, len(ctx.workers[idx].owned))
+			for key, record := range ctx.workers[idx].owned {
+				records[key] = record.val
+			}
+			merger.Merge(idx, 0, records)
+		}(idx)
+	}
+	group.Wait()
+	ctx.failed.report()
+	opts.writeTrace(ctx.recorder)
+	if opts.Stats {
+		writeSSPStats(os.Stderr, ctx.workers)
+	}
+
+	return merger.Result()
+}
@@ -0,0 +1,121 @@
+package modes
+
+import (
+	"proj3/datatest"
+	"proj3/trace"
+	"proj3/utils"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// await must wait for the slowest worker however far the others are ahead, and return once it catches up
+func TestSSPClockWaitsForSlowest(t *testing.T) {
+	clock := newSSPClock(3)
+	for step := 0; step < 3; step++ {
+		clock.advance(0)
+		clock.advance(1)
+	}
+	clock.await(0)
+	clock.await(-2) // what the first supersteps of a worker wait for with a staleness
+
+	done := make(chan struct{})
+	go func() {
+		clock.await(2)
+		close(done)
+	}()
+	for completed := 0; completed < 2; completed++ {
+		select {
+		case <-done:
+			t.Fatalf("await(2) returned with the slowest worker at %v supersteps", completed)
+		case <-time.After(20 * time.Millisecond):
+		}
+		clock.advance(2)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("await(2) still waits with every worker past 2 supersteps")
+	}
+	if clock.slowest != 2 {
+		t.Errorf("slowest worker at %v supersteps, want 2", clock.slowest)
+	}
+}
+
+/*
+No worker may start superstep s before every worker has completed s - staleness supersteps.
+Worker 0 draws files a hundred times larger than the others, so the others run ahead of it as
+far as they may. The supersteps are told apart by the parse spans of their files.
+*/
+func TestSSPStalenessBound(t *testing.T) {
+	const size = 40
+	const numThreads = 4
+	small, large := datatest.Covid(size, 20, 7), datatest.Covid(size, 2000, 7)
+	contents := make(map[int]string)
+	for i := 1; i <= size; i++ {
+		contents[i] = small[i]
+		if (i-1)%numThreads == 0 {
+			contents[i] = large[i]
+		}
+	}
+	datatest.WithFiles(t, contents)
+	args := utils.Arguments{Zipcode: "60601", Month: 3, Year: 2020}
+
+	for _, staleness := range []int{0, 1, 3} {
+		opts := Options{Staleness: staleness}
+		ctx := newSSPContext(&args, size, numThreads, &opts)
+		ctx.recorder = trace.NewRecorder("ssp", numThreads)
+		var group sync.WaitGroup
+		for idx := 0; idx < numThreads; idx++ {
+			group.Add(1)
+			go func(idx int) {
+				defer group.Done()
+				executeSSP(idx, ctx)
+			}(idx)
+		}
+		group.Wait()
+
+		// with one file per task and per superstep, file i is parsed in superstep (i-1)/threads
+		parses := []trace.Event{}
+		for _, event := range ctx.recorder.Events() {
+			if event.Kind == trace.Parse {
+				parses = append(parses, event)
+			}
+		}
+		if len(parses) != size {
+			t.Fatalf("staleness %v: %v files parsed, want %v", staleness, len(parses), size)
+		}
+		for _, started := range parses {
+			step := (started.FileNum - 1) / numThreads
+			for _, completed := range parses {
+				if (completed.FileNum-1)/numThreads < step-staleness && started.Start.Before(completed.End) {
+					t.Errorf("staleness %v: worker %v started superstep %v before worker %v completed superstep %v",
+						staleness, started.Worker, step, completed.Worker, (completed.FileNum-1)/numThreads)
+				}
+			}
+		}
+	}
+}
+
+// Without staleness ssp must give the results of bsp and sequential, merged asynchronously or not
+func TestSSPWithoutStalenessIsBSP(t *testing.T) {
+	const size = 40
+	datatest.WithFiles(t, datatest.Covid(size, 60, 11))
+	queries := []utils.Arguments{{Zipcode: "60603", Month: 5, Year: 2020}, {Zipcode: "60608", Month: 1, Year: 2021},
+		{Zipcode: datatest.OwnZipcode, Month: 3, Year: 2020}}
+	for _, args := range queries {
+		args := args
+		want := RunSequential(&args, size)
+		for _, opts := range []Options{{}, {Superstep: 3}, {ChunkBytes: 1500}} {
+			for _, numThreads := range []int{1, 2, 5} {
+				opts := opts
+				ssp := RunSSP(&args, size, numThreads, &opts)
+				bsp := RunBSP(numThreads, &args, size, &opts)
+				if !reflect.DeepEqual(ssp, want) || !reflect.DeepEqual(bsp, want) {
+					t.Errorf("query %+v, %+v, %v threads: ssp %v, bsp %v, sequential %v", args, opts, numThreads, ssp, bsp, want)
+				}
+			}
+		}
+	}
+}
@@ -1,30 +1,30 @@
 package modes
 
 import (
-	"fmt"
+	"proj3/merge"
+	"proj3/trace"
 	"proj3/utils"
 	"sync"
-	"sync/atomic"
 )
 
 type WorkerContext struct {
-	totalCases  int
-	totalTests  int
-	totalDeaths int
-	records     map[string]bool
-	flag        int32
-	group       *sync.WaitGroup
-	args        *utils.Arguments
+	merger   merge.Merger
+	group    *sync.WaitGroup
+	args     *utils.Arguments
+	recorder *trace.Recorder
 }
 
-func worker(context *WorkerContext, args *utils.Arguments, start int, end int) {
+func worker(context *WorkerContext, args *utils.Arguments, id int, files []int) {
 
 	// compute the total cases, tests, and deaths for the portion assigned
 	workerRecords := make(map[string][]int)
+	recorder := context.recorder
 
-	for i := start; i <= end; i++ {
+	for _, i := range files {
 		fileNum := utils.GetFileNum(i)
+		parseStart := recorder.Now()
 		fileRecords := utils.ParseFile(args, fileNum)
+		recorder.Record(id, trace.Parse, fileNum, parseStart)
 		for key, val := range fileRecords {
 			if _, contains := workerRecords[key]; contains {
 				continue
@@ -33,41 +33,25 @@ func worker(context *WorkerContext, args *utils.Arguments, start int, end int) {
 		}
 	}
 
-	// enter the critical section by updating the global values
-	// exit the critical section after finishing work
-	// use a TTAS lock
-	for true {
-		for context.flag == 1 {
-		} // spin while lock is taken
-		if atomic.CompareAndSwapInt32(&(context.flag), 0, 1) {
-			utils.UpdateGlobal(workerRecords, context.records, &context.totalCases, &context.totalTests, &context.totalDeaths)
-			atomic.StoreInt32(&(context.flag), 0)
-			context.group.Done()
-			return
-		}
-	}
+	// update the global values with the records of the whole portion
+	context.merger.Merge(id, 0, workerRecords)
+	context.group.Done()
 }
 
-func RunStatic(args *utils.Arguments, size int, numThreads int) {
+func RunStatic(args *utils.Arguments, size int, numThreads int, opts *Options) *utils.Result {
 	// Parallel mode:
 	var group sync.WaitGroup
 	context := WorkerContext{group: &group}
-	context.records = make(map[string]bool)
-	workAmount := size / numThreads // static distribution
-	remWork := size % numThreads    // last thread does extra work
+	context.recorder = opts.newRecorder("static", numThreads)
+	context.merger = opts.newMerger(numThreads, context.recorder)
+	groups, _ := partitionFiles(size, numThreads, opts.Partition) // static distribution
 
 	for i := 0; i < numThreads; i++ {
 		context.group.Add(1)
-		startPt := i*workAmount + 1
-		endPt := (i + 1) * workAmount
-		if i == numThreads-1 {
-			endPt += remWork
-		}
-		go worker(&context, args, startPt, endPt)
+		go worker(&context, args, i, groups[i])
 	}
 	group.Wait()
+	opts.writeTrace(context.recorder)
 
-	// final processing of the result and print out to console
-	result := fmt.Sprintf("%v,%v,%v", context.totalCases, context.totalTests, context.totalDeaths)
-	fmt.Println(result)
+	return context.merger.Result()
 }
@@ -2,36 +2,154 @@ package modes
 
 import (
 	"fmt"
+	"os"
+	"proj3/merge"
 	"proj3/stealing"
+	"proj3/trace"
 	"proj3/utils"
-	"sync"
-	"sync/atomic"
 )
 
-func generateTask(fileNum int) func(interface{}) {
-
-	return func(arg interface{}) {
-		ctx := arg.(*stealing.StealingWorkerContext)
-		args := ctx.Args
-		// start counter and set up file path
-		fileRecords := utils.ParseFile(args, fileNum)
-		// finished parsing the file, try to update the global context
-		// enter the critical section by updating the global values
-		// exit the critical section after finishing work
-		// use a TTAS lock
-		for true {
-			for ctx.Flag == 1 {
-			} // spin while lock is taken
-			if atomic.CompareAndSwapInt32(&(ctx.Flag), 0, 1) {
-				utils.UpdateGlobal(fileRecords, ctx.Records, &ctx.TotalCases, &ctx.TotalTests, &ctx.TotalDeaths)
-				atomic.StoreInt32(&(ctx.Flag), 0)
-				return
+// stealingContext is the state shared by all the file tasks of a stealing run
+type stealingContext struct {
+	merger   merge.Merger
+	groups   *merge.Hierarchical // set instead of merger to merge every file into the group it was handed to
+	args     *utils.Arguments
+	recorder *trace.Recorder
+	split    int   // files with more lines than this are split into row ranges, 0 to never split
+	chunk    int64 // files with more bytes than this are split into byte ranges, 0 to never split
+
+	failures // files that could not be read or parsed, their records are missing from the result
+}
+
+/*
+reportFailures prints the files that could not be read or parsed to stderr, and the file tasks
+that panicked, whose records are missing too. It may only be called once the pool is done.
+*/
+func (ctx *stealingContext) reportFailures(pool *stealing.Pool) {
+	ctx.report()
+	for _, err := range pool.Panics() {
+		reportMissing(err)
+	}
+}
+
+/*
+parseRows parses a range of lines of a file. Ranges longer than ctx.split are halved: the
+second half is forked onto the worker's deque, where an idle worker can steal it, while this
+worker carries on with the first half and then joins the second. The first half's records
+win on duplicate keys, the same as parsing the lines in order. If either half fails, e.g. with
+a *stealing.PanicError, the error is returned instead of the records of the other half alone.
+*/
+func parseRows(ctx *stealingContext, worker *stealing.StealingWorker, fileNum int, lines [][]string) (map[string][]int, error) {
+	if len(lines) <= ctx.split {
+		parseStart := ctx.recorder.Now()
+		records := utils.ParseLines(ctx.args, lines)
+		ctx.recorder.Record(worker.ID, trace.Parse, fileNum, parseStart)
+		return records, nil
+	}
+	mid := len(lines) / 2
+	second := stealing.Fork(worker, func(thief *stealing.StealingWorker) (map[string][]int, error) {
+		return parseRows(ctx, thief, fileNum, lines[mid:])
+	})
+	records, err := parseRows(ctx, worker, fileNum, lines[:mid])
+	secondRecords, secondErr := stealing.Join(worker, second)
+	if err != nil {
+		return nil, err
+	}
+	if secondErr != nil {
+		return nil, secondErr
+	}
+	utils.MergeRecords(records, secondRecords)
+	return records, nil
+}
+
+/*
+parseRanges parses byte ranges of a file the same way parseRows parses ranges of lines:
+more than one range is halved, forking the second half and joining it after the first, so
+the ranges of a large file are read and parsed in parallel.
+*/
+func parseRanges(ctx *stealingContext, worker *stealing.StealingWorker, ranges []utils.ByteRange) (map[string][]int, error) {
+	if len(ranges) == 1 {
+		parseStart := ctx.recorder.Now()
+		records, err := utils.ParseRange(ctx.args, ranges[0])
+		ctx.recorder.Record(worker.ID, trace.Parse, ranges[0].FileNum, parseStart)
+		return records, err
+	}
+	mid := len(ranges) / 2
+	second := stealing.Fork(worker, func(thief *stealing.StealingWorker) (map[string][]int, error) {
+		return parseRanges(ctx, thief, ranges[mid:])
+	})
+	records, err := parseRanges(ctx, worker, ranges[:mid])
+	secondRecords, secondErr := stealing.Join(worker, second)
+	if err != nil {
+		return nil, err
+	}
+	if secondErr != nil {
+		return nil, secondErr
+	}
+	utils.MergeRecords(records, secondRecords)
+	return records, nil
+}
+
+// generateTask returns the task parsing fileNum, which was handed to worker owner by the partition
+func generateTask(ctx *stealingContext, owner int, fileNum int) stealing.Runnable {
+
+	return func(worker *stealing.StealingWorker) {
+		args := ctx.args
+		recorder := ctx.recorder
+		// start counter and set up file path, splitting the file up if it is large
+		var fileRecords map[string][]int
+		var err error
+		if ctx.chunk > 0 {
+			var ranges []utils.ByteRange
+			if ranges, err = utils.SplitFile(fileNum, ctx.chunk); err == nil {
+				fileRecords, err = parseRanges(ctx, worker, ranges)
 			}
+		} else if ctx.split > 0 {
+			var lines [][]string
+			if lines, err = utils.ReadFile(fileNum); err == nil {
+				fileRecords, err = parseRows(ctx, worker, fileNum, lines)
+			}
+		} else {
+			parseStart := recorder.Now()
+			fileRecords = utils.ParseFile(args, fileNum)
+			recorder.Record(worker.ID, trace.Parse, fileNum, parseStart)
+		}
+		if err != nil {
+			// the file could not be read or a subtask of it failed, so rather than merging part of the file, report it missing
+			ctx.fail(fileNum, err)
+			fileRecords = nil
+		}
+		// finished parsing the file, update the global context
+		if ctx.groups != nil {
+			// a failed file still counts towards its group being done, with no records
+			ctx.groups.MergeFile(owner, worker.ID, fileNum, fileRecords)
+		} else if err == nil {
+			ctx.merger.Merge(worker.ID, fileNum, fileRecords)
 		}
 	}
 }
 
-func RunStealing(args *utils.Arguments, size int, numThreads int) {
+/*
+dequeConstructor returns the constructor of the deque selected by the options, for workers that
+are each going to be given at most maxTasks tasks. The bounded deque cannot hold more than
+stealing.BoundedCapacity - 1 tasks, nor be pushed to while thieves steal from it, so the
+growable one is used instead when it would overflow, files are split into subtasks or thieves
+steal half.
+*/
+func dequeConstructor(opts *Options, maxTasks int) func() stealing.DEQueue {
+	if opts.Deque == "bounded" {
+		if opts.Split > 0 || opts.ChunkBytes > 0 || opts.Steal == "half" {
+			fmt.Fprintln(os.Stderr, "splitting files and stealing half push onto running workers' deques, using chaselev")
+		} else if maxTasks < stealing.BoundedCapacity {
+			return stealing.NewBoundedDEQueue
+		} else {
+			fmt.Fprintf(os.Stderr, "%v tasks per worker do not fit the bounded deque, using chaselev\n", maxTasks)
+		}
+	}
+	return stealing.NewChaseLevDEQueue
+}
+
+func RunStealing(args *utils.Arguments, size int, numThreads int, opts *Options) *utils.Result {
 	// Parallel mode:
 	/*
 		Assumptions:
@@ -45,56 +163,110 @@ func RunStealing(args *utils.Arguments, size int, numThreads int) {
 		During run-time, if for some reason some thread finishes earlier than others, it will attempt
 		to steal from others so that it doesn't lay idle.
 
+		With the submit option, the queues start out empty instead and the tasks are handed to the
+		workers through Submit while they are already running, the way a producer discovering files
+		would feed them.
+
 		For Step 3 and Step 4, we are implementing mechanisms for the program to wait until everything
-		has been completed. Calling Exit() will notify each worker that there will be no more works
-		enqueued to its local queues so it should focus on stealing work.
-		Threads will keep attempting work stealing until it is notified that all other threads are
-		also emptied. This is done by each thread atomically updating the global context's numEmptied
-		number when its local queue is exhausted, and letting the loop in Run() function detect that change.
+		has been completed. Calling Shutdown() will notify each worker that there will be no more works
+		submitted so it should drain what is left and exit.
+		Threads will keep attempting work stealing until the pool's count of outstanding tasks, which
+		every push and submit increments and every finished task decrements, drops to zero.
 	*/
 	// Step 0: Initialize the global context
-	var group sync.WaitGroup
-	context := stealing.StealingWorkerContext{Group: &group}
-	context.Group.Add(numThreads)
-	context.Records = make(map[string]bool)
-	context.Args = args
-	context.NumThreads = int32(numThreads)
-	context.Queues = make([]stealing.DEQueue, numThreads)
-	context.Workers = make([]*stealing.StealingWorker, numThreads)
+	context := &stealingContext{args: args, split: opts.Split, chunk: opts.ChunkBytes}
+	context.recorder = opts.newRecorder("stealing", numThreads)
+	context.merger = opts.newMerger(numThreads, context.recorder)
+	var newVictims stealing.VictimPolicyFactory
+	if opts.Victim != "" {
+		newVictims, _ = stealing.LookupVictimPolicy(opts.Victim)
+	}
 
+	pool := runStealing(context, size, numThreads, opts, newVictims)
+	context.reportFailures(pool)
+	opts.writeTrace(context.recorder)
+	if opts.Stats {
+		stealing.WriteStats(os.Stderr, pool.Workers())
+	}
+	return context.merger.Result()
+}
+
+/*
+runStealing runs Steps 1 to 4 of RunStealing with the given context and victim policy, nil for
+the pool's default, and returns the pool once all the workers are done. It lets other modes
+run the files on a work-stealing pool with their own merger and victim policy.
+*/
+func runStealing(context *stealingContext, size int, numThreads int, opts *Options, newVictims stealing.VictimPolicyFactory) *stealing.Pool {
 	// Step 1: Initializing the stealing workers and their queues and filling them up
 
-	workAmount := size / numThreads
-	remWork := size % numThreads
-	for i := 0; i < numThreads; i++ {
-		startPt := i*workAmount + 1
-		endPt := (i + 1) * workAmount
-		if i == numThreads-1 {
-			endPt += remWork
+	groups, weights := partitionFiles(size, numThreads, opts.Partition)
+	maxTasks := 0
+	for _, group := range groups {
+		if len(group) > maxTasks {
+			maxTasks = len(group)
 		}
-		context.Queues[i] = stealing.NewBoundedDEQueue()
-		for j := startPt; j <= endPt; j++ {
-			fileNum := utils.GetFileNum(j)
-			task := generateTask(fileNum)
-			context.Queues[i].PushBottom(task)
+	}
+	pool := stealing.NewPool(numThreads, dequeConstructor(opts, maxTasks))
+	if newVictims != nil {
+		pool.SetVictimPolicy(newVictims)
+	}
+	if opts.Steal != "" {
+		amount, _ := stealing.ParseStealAmount(opts.Steal)
+		pool.SetStealAmount(amount)
+	}
+	if opts.Idle != "" {
+		policy, _ := stealing.ParseIdlePolicy(opts.Idle)
+		pool.SetIdlePolicy(policy)
+	}
+	// Files partitioned by bytes are run from the largest to the smallest, so that the small
+	// ones are left to balance the load at the end. The owner pops from the bottom, so the
+	// smallest are pushed first, which also leaves them at the top for thieves.
+	if weights != nil {
+		sortLargestFirst(groups, weights)
+	}
+	owners := make([]int, size+1) // the worker every file index was handed to
+	for i, files := range groups {
+		for _, index := range files {
+			owners[index] = i
+		}
+		if context.groups != nil {
+			context.groups.Expect(i, len(files))
 		}
-		context.Workers[i] = stealing.NewStealingWorker(i, &context, context.Queues, i)
 	}
-
-	// Step 2: Call Run on each of the worker inside the workers slice
-	for i := 0; i < numThreads; i++ {
-		go context.Workers[i].Run()
+	for i, worker := range pool.Workers() {
+		files := groups[i]
+		for j := range files {
+			if opts.Submit {
+				break
+			}
+			index := files[j]
+			if weights != nil {
+				index = files[len(files)-1-j]
+			}
+			worker.Push(generateTask(context, i, utils.GetFileNum(index)))
+		}
 	}
 
-	// Step 3: Call Exit on each of the workers after distributing all works
-	for i := 0; i < numThreads; i++ {
-		context.Workers[i].Exit()
+	// Step 2: Start running each of the workers
+	pool.Start()
+	if opts.Submit {
+		// the inbox is first in, first out, so submit the largest first
+		order := make([]int, size)
+		for j := range order {
+			order[j] = j + 1
+		}
+		if weights != nil {
+			order = largestFirst(size, weights)
+		}
+		for _, index := range order {
+			pool.Execute(generateTask(context, owners[index], utils.GetFileNum(index)))
+		}
 	}
 
+	// Step 3: Shut the workers down after distributing all works
+	pool.Shutdown()
+
 	// Step 4: Wait till all workers have completed
-	group.Wait()
-	// final processing of the result and print out to console
-	result := fmt.Sprintf("%v,%v,%v", context.TotalCases, context.TotalTests, context.TotalDeaths)
-	fmt.Println(result)
-	return
+	pool.Wait()
+	return pool
 }
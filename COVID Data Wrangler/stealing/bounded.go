@@ -5,15 +5,28 @@ import (
 	"unsafe"
 )
 
-type Runnable func(arg interface{})
+// Runnable is an untyped task, it is called with the worker executing it. See Task for typed tasks
+type Runnable func(worker *StealingWorker)
 
 type DEQueue interface {
 	PushBottom(task Runnable)
 	IsEmpty() bool //returns whether the queue is empty
 	PopTop() Runnable
 	PopBottom() Runnable
+	// StealTop is PopTop that also reports whether a nil task was caused by losing the
+	// race for the top to another thread, rather than by the queue being empty
+	StealTop() (task Runnable, contended bool)
+	// Size estimates the number of tasks in the queue, it may be stale by the time it returns
+	Size() int
 }
 
+/*
+BoundedCapacity is the number of tasks a BoundedDEQueue can hold over its lifetime. An emptied
+queue is marked by a top node at this position, so it only works while every position pushed
+stays below it. Use ChaseLevDEQueue when a queue may receive more tasks.
+*/
+const BoundedCapacity = 999
+
 type Node struct {
 	Payload        Runnable
 	Prev           *Node
@@ -25,15 +38,28 @@ type Node struct {
 The Top pointer will point to the actual top (first element of queue), whereas
 BottomSentinel is a sentinel node pointer to a dummy node attached to the actual bottom,
 with position number that's one extra than the actual bottom's number right now.
+Once the worker runs, thieves read both pointers while the owner moves them, so they are
+loaded and stored atomically. The nodes they point to are not changed after the filling.
 */
 type BoundedDEQueue struct {
 	Top            *Node
 	BottomSentinel *Node
 }
 
+func (queue *BoundedDEQueue) loadTop() *Node {
+	return (*Node)(atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(&queue.Top))))
+}
+
+func (queue *BoundedDEQueue) loadBottom() *Node {
+	return (*Node)(atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(&queue.BottomSentinel))))
+}
+
 func NewBoundedDEQueue() DEQueue {
 	bottomSentinel := Node{Payload: nil, Prev: nil, Next: nil, PositionNumber: 0}
-	newDequeue := BoundedDEQueue{Top: nil, BottomSentinel: &bottomSentinel}
+	// a queue that is never pushed to, e.g. under submit or with more workers than files,
+	// still needs a top for thieves to compare with: position 0 marks it empty, like the sentinel
+	emptyTop := Node{Payload: nil, Prev: nil, Next: nil, PositionNumber: 0}
+	newDequeue := BoundedDEQueue{Top: &emptyTop, BottomSentinel: &bottomSentinel}
 	return &newDequeue
 }
 
@@ -43,8 +69,10 @@ Based on the instruction for Q2.3 step no.3, which tells us that the main gorout
 all queues before calling Run for or Exit for any of the workers, I assume that PushBottom can be
 thread-unsafe as we are merely filling up each queues sequentially without concurrently dequeuing / enquing it
 from either top or bottom side
-This also means Top can be nil at initialization and then reference the first node pushed, since
-it's guaranteed that we will push something to a queue for a given worker
+This also means Top can start out as an empty node at position 0 and then reference the first
+node pushed. A queue that is never pushed to keeps the empty node, which IsEmpty, Size and
+StealTop see as empty since the bottom sentinel is at position 0 as well
+Pushing to a running worker needs ChaseLevDEQueue, or submitting through the Pool.
 */
 func (queue *BoundedDEQueue) PushBottom(task Runnable) {
 	// If bottom sentinel number is 0, that means queue is empty
@@ -77,30 +105,44 @@ but it could be that when it actually starts stealing, the
 victim queue has turned empty since last time it was checked. this case will be dealt by PopTop() separately
 */
 func (queue *BoundedDEQueue) IsEmpty() bool {
-	return queue.BottomSentinel.PositionNumber <= queue.Top.PositionNumber
+	return queue.loadBottom().PositionNumber <= queue.loadTop().PositionNumber
+}
+
+func (queue *BoundedDEQueue) Size() int {
+	size := queue.loadBottom().PositionNumber - queue.loadTop().PositionNumber
+	if size < 0 {
+		return 0
+	}
+	return size
 }
 
 /*
 In this implementation Top pointer will only go up in number (go down in queue towards bottom)
 If PopBottom() detects that after its operation the queue becomes empty,
-it will set Top to a dummy node with position number 999.
-This is valid for our program, because we know once queue is emptied, it will not be refilled,
-and we also know that there are only 500 tasks in total, so no matter what thread count we have,
-position number 999 will be the end of the queue
+it will set Top to a dummy node with position number BoundedCapacity (999).
+This is valid because a BoundedDEQueue is only filled before its worker starts, so once it is
+emptied it will not be refilled, and it is never given BoundedCapacity tasks or more, so
+position number 999 is past the end of the queue. Callers that cannot promise both use
+ChaseLevDEQueue instead.
 */
 func (queue *BoundedDEQueue) PopTop() Runnable {
-	oldTop := queue.Top
+	task, _ := queue.StealTop()
+	return task
+}
+
+func (queue *BoundedDEQueue) StealTop() (Runnable, bool) {
+	oldTop := queue.loadTop()
 	newTop := oldTop.Next
 	oldTopNumber := oldTop.PositionNumber
 	task := oldTop.Payload
-	if queue.BottomSentinel.PositionNumber <= oldTopNumber {
-		return nil
+	if queue.loadBottom().PositionNumber <= oldTopNumber {
+		return nil, false
 	}
 	if atomic.CompareAndSwapPointer((*unsafe.Pointer)(unsafe.Pointer(&queue.Top)),
 		unsafe.Pointer(oldTop), unsafe.Pointer(newTop)) {
-		return task
+		return task, false
 	}
-	return nil
+	return nil, true
 }
 
 /*
@@ -111,7 +153,7 @@ In this implementation, we do not have to reset BottomSentinel or Top to 0, sinc
 once queue is emptied, it will never be refilled.
 Therefore, it is sufficient that the Top be set to a number such that any thief trying to
 steal from the queue will be notified that the Top number is large enough to indicate that
-the queue has been emptied. We only have 500 files, so 999 will work.
+the queue has been emptied. No queue is given BoundedCapacity tasks, so 999 will work.
 Furthermore, we know that if PopBottom() fails, it must be that PopTop() has stolen the last
 task, so once PopBottom() returns nil, it must be that the queue is emptied and will never be refilled.
 */
@@ -120,10 +162,10 @@ func (queue *BoundedDEQueue) PopBottom() Runnable {
 		return nil
 	}
 	bottom := queue.BottomSentinel.Prev
-	queue.BottomSentinel = bottom
-	task := queue.BottomSentinel.Payload
-	oldTop := queue.Top
-	newTop := &Node{PositionNumber: 999}
+	atomic.StorePointer((*unsafe.Pointer)(unsafe.Pointer(&queue.BottomSentinel)), unsafe.Pointer(bottom))
+	task := bottom.Payload
+	oldTop := queue.loadTop()
+	newTop := &Node{PositionNumber: BoundedCapacity}
 	oldTopNumber := oldTop.PositionNumber
 	if bottom.PositionNumber > oldTopNumber {
 		return task
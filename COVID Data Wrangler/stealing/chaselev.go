@@ -0,0 +1,158 @@
+package stealing
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// initial number of slots of a ChaseLevDEQueue, the array doubles whenever it fills up
+const chaseLevInitialLogSize = 5
+
+/*
+circularArray is the backing store of a ChaseLevDEQueue. Index i is stored at slot
+i mod size, so top and bottom can keep increasing forever while the array is reused.
+Slots hold a pointer to the Runnable and are read and written atomically, since a thief
+may read a slot while the owner writes a different one in the same array.
+*/
+type circularArray struct {
+	logSize uint
+	slots   []unsafe.Pointer // each is a *Runnable
+}
+
+func newCircularArray(logSize uint) *circularArray {
+	return &circularArray{logSize: logSize, slots: make([]unsafe.Pointer, 1<<logSize)}
+}
+
+func (array *circularArray) size() int64 {
+	return 1 << array.logSize
+}
+
+func (array *circularArray) get(i int64) Runnable {
+	task := (*Runnable)(atomic.LoadPointer(&array.slots[i&(array.size()-1)]))
+	if task == nil {
+		return nil
+	}
+	return *task
+}
+
+func (array *circularArray) put(i int64, task Runnable) {
+	atomic.StorePointer(&array.slots[i&(array.size()-1)], unsafe.Pointer(&task))
+}
+
+// grow returns a copy of the array with twice the slots, holding the elements in [top, bottom)
+func (array *circularArray) grow(bottom int64, top int64) *circularArray {
+	grown := newCircularArray(array.logSize + 1)
+	for i := top; i < bottom; i++ {
+		grown.put(i, array.get(i))
+	}
+	return grown
+}
+
+/*
+ChaseLevDEQueue is the unbounded work-stealing deque of Chase and Lev ("Dynamic Circular
+Work-Stealing Deque", SPAA 2005). Unlike BoundedDEQueue it has no limit on the number of
+tasks, the owner may push while thieves are stealing, and it can be refilled after it has
+been emptied.
+
+The owner pushes and pops at bottom, thieves pop at top. Top only ever increases and is only
+changed by CAS, so it doubles as the stamp that BoundedDEQueue gets from its node positions:
+a thief whose CAS succeeds knows no other thread took the element at that index in the
+meantime, and since indices are never reused (int64 will not wrap in practice) there is no
+ABA problem. The only contended case is the last element, which the owner also claims with
+a CAS on top.
+
+When the array is full the owner replaces it with a copy twice the size. Thieves that
+still hold the old array read the same elements from it, since the owner never writes
+to an array again once it has been replaced.
+*/
+type ChaseLevDEQueue struct {
+	top    int64          // next index to steal, only increased by CAS
+	bottom int64          // next index to push, only written by the owner
+	array  unsafe.Pointer // current *circularArray
+}
+
+func NewChaseLevDEQueue() DEQueue {
+	return &ChaseLevDEQueue{array: unsafe.Pointer(newCircularArray(chaseLevInitialLogSize))}
+}
+
+func (queue *ChaseLevDEQueue) loadArray() *circularArray {
+	return (*circularArray)(atomic.LoadPointer(&queue.array))
+}
+
+// PushBottom may only be called by the owner, but is safe to call while thieves steal
+func (queue *ChaseLevDEQueue) PushBottom(task Runnable) {
+	bottom := atomic.LoadInt64(&queue.bottom)
+	top := atomic.LoadInt64(&queue.top)
+	array := queue.loadArray()
+	if bottom-top >= array.size()-1 {
+		array = array.grow(bottom, top)
+		atomic.StorePointer(&queue.array, unsafe.Pointer(array))
+	}
+	array.put(bottom, task)
+	atomic.StoreInt64(&queue.bottom, bottom+1)
+}
+
+// IsEmpty reports whether the queue was empty at the time top and bottom were read
+func (queue *ChaseLevDEQueue) IsEmpty() bool {
+	top := atomic.LoadInt64(&queue.top)
+	bottom := atomic.LoadInt64(&queue.bottom)
+	return bottom <= top
+}
+
+func (queue *ChaseLevDEQueue) Size() int {
+	top := atomic.LoadInt64(&queue.top)
+	bottom := atomic.LoadInt64(&queue.bottom)
+	if bottom <= top {
+		return 0
+	}
+	return int(bottom - top)
+}
+
+func (queue *ChaseLevDEQueue) PopTop() Runnable {
+	task, _ := queue.StealTop()
+	return task
+}
+
+func (queue *ChaseLevDEQueue) StealTop() (Runnable, bool) {
+	top := atomic.LoadInt64(&queue.top)
+	bottom := atomic.LoadInt64(&queue.bottom)
+	if bottom <= top {
+		return nil, false
+	}
+	// read the task before claiming it, once top moves past it the owner may overwrite the slot
+	task := queue.loadArray().get(top)
+	if !atomic.CompareAndSwapInt64(&queue.top, top, top+1) {
+		return nil, true
+	}
+	return task, false
+}
+
+/*
+PopBottom may only be called by the owner. Bottom is decremented before top is read, so a
+thief that reads bottom afterwards sees the element as gone, and if top has not passed it
+the element is the owner's. Go's atomics are sequentially consistent, which provides the
+store-load fence the algorithm needs between the two.
+*/
+func (queue *ChaseLevDEQueue) PopBottom() Runnable {
+	bottom := atomic.LoadInt64(&queue.bottom) - 1
+	array := queue.loadArray()
+	atomic.StoreInt64(&queue.bottom, bottom)
+	top := atomic.LoadInt64(&queue.top)
+
+	if bottom < top {
+		// already empty, restore bottom so that bottom == top
+		atomic.StoreInt64(&queue.bottom, top)
+		return nil
+	}
+	task := array.get(bottom)
+	if bottom > top {
+		return task
+	}
+
+	// last element, race the thieves for it by claiming top
+	if !atomic.CompareAndSwapInt64(&queue.top, top, top+1) {
+		task = nil
+	}
+	atomic.StoreInt64(&queue.bottom, top+1)
+	return task
+}
@@ -0,0 +1,108 @@
+package stealing
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// countingTasks returns n tasks that each count their runs into runs[i]
+func countingTasks(n int) ([]Runnable, []int32) {
+	runs := make([]int32, n)
+	tasks := make([]Runnable, n)
+	for i := range tasks {
+		i := i
+		tasks[i] = func(worker *StealingWorker) { atomic.AddInt32(&runs[i], 1) }
+	}
+	return tasks, runs
+}
+
+func checkRanOnce(t *testing.T, runs []int32) {
+	t.Helper()
+	for i, count := range runs {
+		if count != 1 {
+			t.Fatalf("task %v ran %v times, want 1", i, count)
+		}
+	}
+}
+
+func TestChaseLevGrowsPastInitialCapacity(t *testing.T) {
+	queue := NewChaseLevDEQueue()
+	n := 10 << chaseLevInitialLogSize
+	tasks, runs := countingTasks(n)
+	for _, task := range tasks {
+		queue.PushBottom(task)
+	}
+	if size := queue.Size(); size != n {
+		t.Fatalf("size %v after %v pushes", size, n)
+	}
+	// half from the top in push order, the rest from the bottom in reverse
+	for i := 0; i < n/2; i++ {
+		queue.PopTop()(nil)
+		if runs[i] != 1 {
+			t.Fatalf("PopTop returned task out of order at %v", i)
+		}
+	}
+	for i := n - 1; i >= n/2; i-- {
+		queue.PopBottom()(nil)
+		if runs[i] != 1 {
+			t.Fatalf("PopBottom returned task out of order at %v", i)
+		}
+	}
+	if !queue.IsEmpty() || queue.PopBottom() != nil || queue.PopTop() != nil {
+		t.Fatal("queue not empty after popping every task")
+	}
+	checkRanOnce(t, runs)
+}
+
+func TestChaseLevRefillAfterEmptying(t *testing.T) {
+	queue := NewChaseLevDEQueue()
+	for round := 0; round < 5; round++ {
+		tasks, runs := countingTasks(3 << chaseLevInitialLogSize)
+		for _, task := range tasks {
+			queue.PushBottom(task)
+		}
+		for task := queue.PopBottom(); task != nil; task = queue.PopBottom() {
+			task(nil)
+		}
+		if !queue.IsEmpty() || queue.Size() != 0 {
+			t.Fatalf("round %v: queue not empty", round)
+		}
+		checkRanOnce(t, runs)
+	}
+}
+
+// The owner pushes and pops at the bottom while thieves steal from the top, growing the array on the way
+func TestChaseLevOwnerRacesThieves(t *testing.T) {
+	const n = 20000
+	const thieves = 4
+	queue := NewChaseLevDEQueue()
+	tasks, runs := countingTasks(n)
+	var done int32
+	var group sync.WaitGroup
+	for i := 0; i < thieves; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			for atomic.LoadInt32(&done) == 0 || !queue.IsEmpty() {
+				if task, _ := queue.StealTop(); task != nil {
+					task(nil)
+				}
+			}
+		}()
+	}
+	for i, task := range tasks {
+		queue.PushBottom(task)
+		if i%3 == 0 {
+			if popped := queue.PopBottom(); popped != nil {
+				popped(nil)
+			}
+		}
+	}
+	for task := queue.PopBottom(); task != nil; task = queue.PopBottom() {
+		task(nil)
+	}
+	atomic.StoreInt32(&done, 1)
+	group.Wait()
+	checkRanOnce(t, runs)
+}
@@ -0,0 +1,151 @@
+package stealing
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// Task is a typed unit of work, run by the worker passed to it
+type Task[T any] func(worker *StealingWorker) (T, error)
+
+// Result is the outcome of a task, as delivered on a results channel
+type Result[T any] struct {
+	Value T
+	Err   error
+}
+
+// PanicError is the error reported for a task that panicked instead of returning
+type PanicError struct {
+	Value interface{} // the value passed to panic
+	Stack []byte      // the stack of the task when it panicked
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("stealing: task panicked: %v\n%s", err.Value, err.Stack)
+}
+
+// Future is the eventual result of a task
+type Future[T any] struct {
+	done    chan struct{}
+	value   T
+	err     error
+	task    Task[T] // the task of a forked future, which either Join or a worker claims to run
+	claimed int32   // set to 1 by whoever claims task
+}
+
+func newFuture[T any]() *Future[T] {
+	return &Future[T]{done: make(chan struct{})}
+}
+
+func (future *Future[T]) complete(value T, err error) {
+	future.value = value
+	future.err = err
+	close(future.done)
+}
+
+// Get blocks until the task has completed and returns its result
+func (future *Future[T]) Get() (T, error) {
+	<-future.done
+	return future.value, future.err
+}
+
+// claim reports whether the caller may run the forked task, which only one caller may
+func (future *Future[T]) claim() bool {
+	return atomic.CompareAndSwapInt32(&future.claimed, 0, 1)
+}
+
+func (future *Future[T]) isDone() bool {
+	select {
+	case <-future.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Done returns a channel that is closed once the task has completed
+func (future *Future[T]) Done() <-chan struct{} {
+	return future.done
+}
+
+// call runs a task, turning a panic into a *PanicError so that it cannot take the worker down
+func call[T any](worker *StealingWorker, task Task[T]) (value T, err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = &PanicError{Value: recovered, Stack: debug.Stack()}
+		}
+	}()
+	return task(worker)
+}
+
+// toRunnable adapts a typed task into a Runnable that passes its result to complete
+func toRunnable[T any](task Task[T], complete func(T, error)) Runnable {
+	return func(worker *StealingWorker) {
+		complete(call(worker, task))
+	}
+}
+
+// Submit hands a typed task to the running pool and returns a future for its result
+func Submit[T any](pool *Pool, task Task[T]) (*Future[T], error) {
+	future := newFuture[T]()
+	if err := pool.Execute(toRunnable(task, future.complete)); err != nil {
+		return nil, err
+	}
+	return future, nil
+}
+
+/*
+SubmitTo hands a typed task to the running pool, and sends its result on results once it
+completes. The worker blocks on the send, so results should be buffered or drained promptly.
+*/
+func SubmitTo[T any](pool *Pool, task Task[T], results chan<- Result[T]) error {
+	return pool.Execute(toRunnable(task, func(value T, err error) {
+		results <- Result[T]{Value: value, Err: err}
+	}))
+}
+
+/*
+Fork pushes a typed child task onto the bottom of worker's own deque and returns a future for
+its result. It is meant to be called by a task running on worker, which then carries on with
+its own share of the work and calls Join for the child. Meanwhile idle workers steal from the
+top of the deque, that is the oldest and usually largest pending pieces of work. It may also
+be called before the pool starts, to distribute work up front.
+*/
+func Fork[T any](worker *StealingWorker, task Task[T]) *Future[T] {
+	future := newFuture[T]()
+	future.task = task
+	worker.Push(func(runner *StealingWorker) {
+		// Join may have run the task already, leaving this entry of the deque with nothing to do
+		if future.claim() {
+			future.complete(call(runner, task))
+		}
+	})
+	return future
+}
+
+// joinSpins is how many times Join yields for a stolen child to complete before it blocks
+const joinSpins = 64
+
+/*
+Join waits for a task forked by a task running on worker and returns its result. If no worker
+has started the child yet, which is the case unless it was stolen, Join runs it right away on
+the joining worker, and the child's entry in the deque is skipped once it is popped. Otherwise
+the child is running on a thief, and Join yields for a while in case it is about to complete,
+then blocks until it has.
+
+Join never runs any task but the child. Running other tasks while waiting would keep the worker
+busy, but could pile up unrelated tasks on the joining stack without bound, and leave the
+joining task waiting for whichever of them takes the longest.
+*/
+func Join[T any](worker *StealingWorker, future *Future[T]) (T, error) {
+	if future.claim() {
+		future.complete(call(worker, future.task))
+		return future.Get()
+	}
+	for spins := 0; spins < joinSpins && !future.isDone(); spins++ {
+		runtime.Gosched()
+	}
+	return future.Get()
+}
@@ -0,0 +1,132 @@
+package stealing
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// IdlePolicy is what a worker does after failing to find work
+type IdlePolicy int
+
+const (
+	IdleSpin    IdlePolicy = iota // yield with runtime.Gosched and try again straight away
+	IdleBackoff                   // yield at first, then sleep for exponentially longer
+	IdlePark                      // back off, then park until new work is pushed or the pool exits
+)
+
+// tuning of the backoff: the number of failed attempts that only yield, and the sleep bounds
+const (
+	idleSpins       = 16
+	minIdleSleep    = time.Microsecond
+	maxIdleSleep    = time.Millisecond
+	idleParkRetries = 4 // attempts at the longest sleep before parking
+)
+
+// ParseIdlePolicy parses "spin", "backoff" or "park"
+func ParseIdlePolicy(name string) (IdlePolicy, error) {
+	switch name {
+	case "spin":
+		return IdleSpin, nil
+	case "backoff":
+		return IdleBackoff, nil
+	case "park":
+		return IdlePark, nil
+	}
+	return IdleSpin, fmt.Errorf("unknown idle policy %q, want spin, backoff or park", name)
+}
+
+/*
+idle is called by Run each time the worker failed to find any work, with the number of
+consecutive failures so far. Depending on the pool's policy it yields, sleeps for a backoff
+that doubles with every failure, or parks once the backoff has reached its maximum.
+*/
+func (worker *StealingWorker) idle(failures int) {
+	pool := worker.Pool
+	if pool.idlePolicy == IdleSpin || failures <= idleSpins {
+		runtime.Gosched()
+		worker.Stats.Yields++
+		return
+	}
+
+	shift := failures - idleSpins - 1
+	if pool.idlePolicy == IdlePark && shift >= idleBackoffSteps+idleParkRetries {
+		pool.park(worker)
+		return
+	}
+	sleep := maxIdleSleep
+	if shift < idleBackoffSteps {
+		sleep = minIdleSleep << uint(shift)
+	}
+	time.Sleep(sleep)
+	worker.Stats.Sleeps++
+}
+
+// idleBackoffSteps is the number of doublings from the shortest to the longest sleep
+var idleBackoffSteps = func() int {
+	steps := 0
+	for sleep := minIdleSleep; sleep < maxIdleSleep; sleep *= 2 {
+		steps++
+	}
+	return steps
+}()
+
+/*
+park blocks the worker until work is pushed anywhere in the pool, or the pool may be exiting.
+
+A wake-up cannot be lost: the worker counts itself as parked before it looks for work one
+last time, while whoever makes work available publishes it before checking whether anyone
+is parked. Go's atomics are sequentially consistent, so either the worker sees the work, or
+the pusher sees the parked worker and wakes it. The wake-up is delivered under parkMutex
+by bumping parkEpoch, which the worker only waits on after taking the same lock.
+*/
+func (pool *Pool) park(worker *StealingWorker) {
+	pool.parkMutex.Lock()
+	defer pool.parkMutex.Unlock()
+	atomic.AddInt32(&pool.parked, 1)
+	defer atomic.AddInt32(&pool.parked, -1)
+	if pool.hasWork() || pool.terminated() {
+		return
+	}
+	worker.Stats.Parks++
+	epoch := pool.parkEpoch
+	for pool.parkEpoch == epoch {
+		pool.parkCond.Wait()
+	}
+}
+
+// hasWork reports whether any task was waiting in the inbox or a deque at the time of the call
+func (pool *Pool) hasWork() bool {
+	if atomic.LoadInt32(&pool.inbox.length) > 0 {
+		return true
+	}
+	for _, queue := range pool.queues {
+		if !queue.IsEmpty() {
+			return true
+		}
+	}
+	return false
+}
+
+// notify wakes one parked worker, if there is any, after a task has been made available
+func (pool *Pool) notify() {
+	if atomic.LoadInt32(&pool.parked) == 0 {
+		return
+	}
+	pool.parkMutex.Lock()
+	pool.parkEpoch++
+	pool.parkMutex.Unlock()
+	pool.parkCond.Signal()
+}
+
+// notifyAll wakes every parked worker, so that they can check whether to exit
+func (pool *Pool) notifyAll() {
+	if atomic.LoadInt32(&pool.parked) == 0 {
+		return
+	}
+	pool.parkMutex.Lock()
+	pool.parkEpoch++
+	pool.parkMutex.Unlock()
+	pool.parkCond.Broadcast()
+}
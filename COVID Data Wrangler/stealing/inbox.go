@@ -0,0 +1,62 @@
+package stealing
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is returned when a task is submitted after the workers have been shut down
+var ErrClosed = errors.New("stealing: submit after shutdown")
+
+/*
+Inbox is the queue through which goroutines other than the workers hand tasks to a running
+pool. Only the owner of a deque may push to it, so external producers cannot push to the
+workers' deques directly. Instead they put tasks here, and idle workers take them before
+they go stealing.
+
+Any number of producers and workers may use it concurrently. The length is kept in an atomic
+as well, so that idle workers can check for submitted tasks without taking the lock.
+*/
+type Inbox struct {
+	mutex  sync.Mutex
+	tasks  []Runnable
+	length int32
+	closed bool
+}
+
+// Put queues a task, or returns ErrClosed if the inbox has been closed
+func (inbox *Inbox) Put(task Runnable) error {
+	inbox.mutex.Lock()
+	defer inbox.mutex.Unlock()
+	if inbox.closed {
+		return ErrClosed
+	}
+	inbox.tasks = append(inbox.tasks, task)
+	atomic.AddInt32(&inbox.length, 1)
+	return nil
+}
+
+// Take removes the oldest submitted task, or returns nil if there is none
+func (inbox *Inbox) Take() Runnable {
+	if atomic.LoadInt32(&inbox.length) == 0 {
+		return nil
+	}
+	inbox.mutex.Lock()
+	defer inbox.mutex.Unlock()
+	if len(inbox.tasks) == 0 {
+		return nil
+	}
+	task := inbox.tasks[0]
+	inbox.tasks[0] = nil
+	inbox.tasks = inbox.tasks[1:]
+	atomic.AddInt32(&inbox.length, -1)
+	return task
+}
+
+// Close stops the inbox from accepting tasks, the ones already queued can still be taken
+func (inbox *Inbox) Close() {
+	inbox.mutex.Lock()
+	inbox.closed = true
+	inbox.mutex.Unlock()
+}
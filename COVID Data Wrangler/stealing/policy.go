@@ -0,0 +1,242 @@
+package stealing
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+/*
+VictimPolicy decides which worker an idle worker tries to steal from next. Every worker gets
+its own policy, so a policy may keep state about its worker's past steals without locking.
+*/
+type VictimPolicy interface {
+	// Victim returns the ID of the worker to steal from next. Returning the thief's own ID
+	// means there is no worth-while victim right now.
+	Victim() int
+	// Observe is told whether the attempt on victim got a task
+	Observe(victim int, stolen bool)
+}
+
+// A VictimPolicyFactory creates the policy of one worker of a pool
+type VictimPolicyFactory func(worker *StealingWorker) VictimPolicy
+
+// VictimPolicies are the available victim policies by name
+var VictimPolicies = map[string]VictimPolicyFactory{
+	"random":     NewRandomVictims,
+	"roundrobin": NewRoundRobinVictims,
+	"last":       NewLastVictims,
+	"mostloaded": NewMostLoadedVictims,
+	"p2c":        NewTwoChoiceVictims,
+}
+
+// VictimPolicyNames lists the names in VictimPolicies, sorted
+func VictimPolicyNames() []string {
+	names := []string{}
+	for name := range VictimPolicies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupVictimPolicy returns the factory registered under name
+func LookupVictimPolicy(name string) (VictimPolicyFactory, error) {
+	factory, ok := VictimPolicies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown victim policy %q, want one of %v", name, VictimPolicyNames())
+	}
+	return factory, nil
+}
+
+// newWorkerRand gives every worker its own source, so picking a victim never contends on
+// the lock behind the global math/rand functions
+func newWorkerRand(worker *StealingWorker) *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano() + int64(worker.ID)*7919))
+}
+
+// randomVictims picks a victim uniformly at random, the original behaviour
+type randomVictims struct {
+	worker *StealingWorker
+	rng    *rand.Rand
+}
+
+func NewRandomVictims(worker *StealingWorker) VictimPolicy {
+	return &randomVictims{worker: worker, rng: newWorkerRand(worker)}
+}
+
+func (policy *randomVictims) Victim() int {
+	return policy.rng.Intn(len(policy.worker.Pool.queues))
+}
+
+func (policy *randomVictims) Observe(victim int, stolen bool) {}
+
+// roundRobinVictims visits the other workers in turn, starting with the next ID up
+type roundRobinVictims struct {
+	worker *StealingWorker
+	next   int
+}
+
+func NewRoundRobinVictims(worker *StealingWorker) VictimPolicy {
+	return &roundRobinVictims{worker: worker, next: worker.ID + 1}
+}
+
+func (policy *roundRobinVictims) Victim() int {
+	numThreads := len(policy.worker.Pool.queues)
+	victim := policy.next % numThreads
+	if victim == policy.worker.ID && numThreads > 1 {
+		victim = (victim + 1) % numThreads
+	}
+	policy.next = victim + 1
+	return victim
+}
+
+func (policy *roundRobinVictims) Observe(victim int, stolen bool) {}
+
+// lastVictims goes back to the last victim it stole from until that fails, then picks at random
+type lastVictims struct {
+	random *randomVictims
+	last   int
+}
+
+func NewLastVictims(worker *StealingWorker) VictimPolicy {
+	return &lastVictims{random: NewRandomVictims(worker).(*randomVictims), last: -1}
+}
+
+func (policy *lastVictims) Victim() int {
+	if policy.last >= 0 {
+		return policy.last
+	}
+	return policy.random.Victim()
+}
+
+func (policy *lastVictims) Observe(victim int, stolen bool) {
+	if stolen {
+		policy.last = victim
+	} else {
+		policy.last = -1
+	}
+}
+
+/*
+mostLoadedVictims scans every deque and picks the one with the most tasks. The sizes are
+estimates read without synchronisation, so the choice may be stale, and the scan costs a
+read of every deque per attempt.
+*/
+type mostLoadedVictims struct {
+	worker *StealingWorker
+}
+
+func NewMostLoadedVictims(worker *StealingWorker) VictimPolicy {
+	return &mostLoadedVictims{worker: worker}
+}
+
+func (policy *mostLoadedVictims) Victim() int {
+	victim, most := policy.worker.ID, 0
+	for id, queue := range policy.worker.Pool.queues {
+		if id == policy.worker.ID {
+			continue
+		}
+		if size := queue.Size(); size > most {
+			victim, most = id, size
+		}
+	}
+	return victim
+}
+
+func (policy *mostLoadedVictims) Observe(victim int, stolen bool) {}
+
+// twoChoiceVictims samples two workers at random and picks the one with more tasks
+type twoChoiceVictims struct {
+	random *randomVictims
+}
+
+func NewTwoChoiceVictims(worker *StealingWorker) VictimPolicy {
+	return &twoChoiceVictims{random: NewRandomVictims(worker).(*randomVictims)}
+}
+
+func (policy *twoChoiceVictims) Victim() int {
+	queues := policy.random.worker.Pool.queues
+	first, second := policy.random.Victim(), policy.random.Victim()
+	if queues[second].Size() > queues[first].Size() {
+		return second
+	}
+	return first
+}
+
+func (policy *twoChoiceVictims) Observe(victim int, stolen bool) {}
+
+/*
+groupVictims prefers the workers of its own group, the groupSize consecutive workers its ID
+falls in, to keep stolen work and what it touches close to the thief. It picks a random other
+worker of the group until groupSize-1 attempts in a row have come back empty, then makes one
+attempt on a random worker outside the group before returning to the group. Any successful
+steal starts the count afresh.
+*/
+type groupVictims struct {
+	random   *randomVictims
+	first    int // ID of the first worker of the group
+	size     int // number of workers in the group
+	failures int // attempts in a row that got nothing
+}
+
+// NewGroupVictims returns the factory of groupVictims policies for groups of groupSize workers
+func NewGroupVictims(groupSize int) VictimPolicyFactory {
+	return func(worker *StealingWorker) VictimPolicy {
+		first := worker.ID / groupSize * groupSize
+		size := groupSize
+		if numThreads := len(worker.Pool.queues); first+size > numThreads {
+			size = numThreads - first
+		}
+		return &groupVictims{random: NewRandomVictims(worker).(*randomVictims), first: first, size: size}
+	}
+}
+
+func (policy *groupVictims) Victim() int {
+	worker, rng := policy.random.worker, policy.random.rng
+	numThreads := len(worker.Pool.queues)
+	if policy.failures < policy.size-1 || numThreads == policy.size {
+		if policy.size == 1 {
+			return worker.ID
+		}
+		victim := policy.first + rng.Intn(policy.size-1)
+		if victim >= worker.ID {
+			victim++
+		}
+		return victim
+	}
+	victim := rng.Intn(numThreads - policy.size)
+	if victim >= policy.first {
+		victim += policy.size
+	}
+	return victim
+}
+
+func (policy *groupVictims) Observe(victim int, stolen bool) {
+	inGroup := victim >= policy.first && victim < policy.first+policy.size
+	if stolen || !inGroup {
+		policy.failures = 0
+	} else {
+		policy.failures++
+	}
+}
+
+// StealAmount is how many tasks a thief takes from its victim at once
+type StealAmount int
+
+const (
+	StealOne  StealAmount = iota // take the top task only
+	StealHalf                    // take half of the victim's tasks, running one and keeping the rest
+)
+
+// ParseStealAmount parses "one" or "half"
+func ParseStealAmount(name string) (StealAmount, error) {
+	switch name {
+	case "one":
+		return StealOne, nil
+	case "half":
+		return StealHalf, nil
+	}
+	return StealOne, fmt.Errorf("unknown steal amount %q, want one or half", name)
+}
@@ -0,0 +1,144 @@
+package stealing
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+/*
+Pool is a set of work-stealing workers, one deque each, plus an inbox for tasks submitted
+from outside. It knows nothing about what the tasks do: anything the tasks share is captured
+by the tasks themselves.
+
+Tasks can be pushed onto the workers' deques before Start to distribute work up front, and
+submitted from any goroutine once the pool is running. Shutdown closes submissions and
+lets the workers exit once all the work has drained, and Wait blocks until they have.
+*/
+type Pool struct {
+	queues      []DEQueue
+	workers     []*StealingWorker
+	inbox       Inbox
+	group       sync.WaitGroup
+	pending     int64 // tasks pushed or submitted that have not finished running
+	closed      int32 // set to 1 by Shutdown
+	newVictims  VictimPolicyFactory
+	stealAmount StealAmount
+	idlePolicy  IdlePolicy
+	parkMutex   sync.Mutex
+	parkCond    *sync.Cond
+	parkEpoch   uint64 // bumped under parkMutex to wake parked workers, see park
+	parked      int32  // number of workers in park
+}
+
+// NewPool creates a pool of numThreads workers, each with a deque made by newDEQueue
+func NewPool(numThreads int, newDEQueue func() DEQueue) *Pool {
+	pool := &Pool{newVictims: NewRandomVictims, stealAmount: StealOne, idlePolicy: IdleSpin}
+	pool.parkCond = sync.NewCond(&pool.parkMutex)
+	pool.queues = make([]DEQueue, numThreads)
+	pool.workers = make([]*StealingWorker, numThreads)
+	for i := 0; i < numThreads; i++ {
+		pool.queues[i] = newDEQueue()
+		pool.workers[i] = &StealingWorker{LocalQueue: pool.queues[i], ID: i, Pool: pool}
+	}
+	return pool
+}
+
+// Workers returns the workers of the pool, indexed by their ID
+func (pool *Pool) Workers() []*StealingWorker {
+	return pool.workers
+}
+
+// SetVictimPolicy sets how idle workers choose whom to steal from, random by default.
+// It must be called before Start
+func (pool *Pool) SetVictimPolicy(newVictims VictimPolicyFactory) {
+	pool.newVictims = newVictims
+}
+
+/*
+SetStealAmount sets how many tasks a thief takes at once, one by default. It must be called
+before Start. With StealHalf the thief pushes the extra tasks onto its own deque while others
+may steal from it, which requires a deque like ChaseLevDEQueue.
+*/
+func (pool *Pool) SetStealAmount(amount StealAmount) {
+	pool.stealAmount = amount
+}
+
+/*
+SetIdlePolicy sets what workers do when they find nothing to run, IdleSpin by default. IdleSpin
+reacts fastest but keeps every idle worker on a core, which hurts other processes on a shared
+host. It must be called before Start.
+*/
+func (pool *Pool) SetIdlePolicy(policy IdlePolicy) {
+	pool.idlePolicy = policy
+}
+
+// Start runs every worker on its own goroutine
+func (pool *Pool) Start() {
+	pool.group.Add(len(pool.workers))
+	for _, worker := range pool.workers {
+		worker.victims = pool.newVictims(worker)
+		go worker.Run()
+	}
+}
+
+// Execute hands an untyped task to the running workers from any goroutine. It returns
+// ErrClosed once Shutdown has been called. See Submit for tasks with results.
+func (pool *Pool) Execute(task Runnable) error {
+	// count the task before it can be taken, so it cannot finish before it was counted
+	atomic.AddInt64(&pool.pending, 1)
+	if err := pool.inbox.Put(task); err != nil {
+		pool.finish()
+		return err
+	}
+	pool.notify()
+	return nil
+}
+
+/*
+Shutdown stops accepting submitted tasks and tells every worker to exit once all the work has
+been drained: the tasks already submitted, the tasks in every deque, and any tasks those push
+while running.
+*/
+func (pool *Pool) Shutdown() {
+	pool.inbox.Close()
+	atomic.StoreInt32(&pool.closed, 1)
+	pool.notifyAll()
+}
+
+// finish marks one outstanding task as done, waking parked workers to exit if it was the last
+func (pool *Pool) finish() {
+	if atomic.AddInt64(&pool.pending, -1) == 0 {
+		pool.notifyAll()
+	}
+}
+
+/*
+terminated reports whether the workers may exit: the pool has been shut down and no task is
+outstanding. Every task is counted when it is pushed or submitted and only uncounted once it
+has finished running, after anything it pushed or submitted itself. So the count can only
+drop to zero when no task is queued or running anywhere, and after Shutdown nothing but a
+running task could add one. Once terminated it therefore stays terminated.
+
+The closed flag is read first. A submission that was accepted happened before Shutdown, so
+its count is visible by then, and a rejected one only delays the exit while it is undone.
+*/
+func (pool *Pool) terminated() bool {
+	return atomic.LoadInt32(&pool.closed) == 1 && atomic.LoadInt64(&pool.pending) == 0
+}
+
+// Wait blocks until every worker has exited after Shutdown
+func (pool *Pool) Wait() {
+	pool.group.Wait()
+}
+
+/*
+Panics returns the panics of the plain Runnables that panicked, by worker. Typed tasks report
+theirs through their futures instead. It may only be called after Wait.
+*/
+func (pool *Pool) Panics() []*PanicError {
+	panics := []*PanicError{}
+	for _, worker := range pool.workers {
+		panics = append(panics, worker.panics...)
+	}
+	return panics
+}
@@ -0,0 +1,295 @@
+package stealing
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitOrFail waits for the pool to exit after Shutdown, failing the test if it does not terminate
+func waitOrFail(t *testing.T, pool *Pool) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		pool.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("pool did not terminate")
+	}
+}
+
+// sumRange adds up the numbers from start to end exclusive, forking the second half of long ranges
+func sumRange(worker *StealingWorker, start int, end int) (int, error) {
+	if end-start <= 8 {
+		sum := 0
+		for i := start; i < end; i++ {
+			sum += i
+		}
+		return sum, nil
+	}
+	mid := (start + end) / 2
+	second := Fork(worker, func(thief *StealingWorker) (int, error) {
+		return sumRange(thief, mid, end)
+	})
+	first, err := sumRange(worker, start, mid)
+	if err != nil {
+		return 0, err
+	}
+	secondSum, err := Join(worker, second)
+	return first + secondSum, err
+}
+
+func TestPushedTasksRunOnce(t *testing.T) {
+	deques := map[string]func() DEQueue{"chaselev": NewChaseLevDEQueue, "bounded": NewBoundedDEQueue}
+	for name, newDEQueue := range deques {
+		for _, idle := range []IdlePolicy{IdleSpin, IdleBackoff, IdlePark} {
+			pool := NewPool(6, newDEQueue)
+			pool.SetIdlePolicy(idle)
+			tasks, runs := countingTasks(500)
+			// everything on the first workers, the others start empty and have to steal. The
+			// tasks yield, so that the thieves get to run while the owners pop
+			for i, task := range tasks {
+				task := task
+				pool.Workers()[i%2].Push(func(worker *StealingWorker) {
+					runtime.Gosched()
+					task(worker)
+				})
+			}
+			pool.Start()
+			pool.Shutdown()
+			waitOrFail(t, pool)
+			for i, count := range runs {
+				if count != 1 {
+					t.Fatalf("%v, idle %v: task %v ran %v times", name, idle, i, count)
+				}
+			}
+		}
+	}
+}
+
+func TestSubmitAndSubmitTo(t *testing.T) {
+	pool := NewPool(4, NewChaseLevDEQueue)
+	pool.Start()
+	futures := []*Future[int]{}
+	results := make(chan Result[int], 100)
+	for i := 0; i < 100; i++ {
+		i := i
+		future, err := Submit(pool, func(worker *StealingWorker) (int, error) { return i * i, nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		futures = append(futures, future)
+		if err := SubmitTo(pool, func(worker *StealingWorker) (int, error) { return i, nil }, results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, future := range futures {
+		if value, err := future.Get(); err != nil || value != i*i {
+			t.Fatalf("future %v: got %v, %v", i, value, err)
+		}
+	}
+	sum := 0
+	for i := 0; i < 100; i++ {
+		result := <-results
+		if result.Err != nil {
+			t.Fatal(result.Err)
+		}
+		sum += result.Value
+	}
+	if sum != 99*100/2 {
+		t.Fatalf("SubmitTo results add up to %v", sum)
+	}
+	pool.Shutdown()
+	waitOrFail(t, pool)
+}
+
+func TestForkJoin(t *testing.T) {
+	pool := NewPool(4, NewChaseLevDEQueue)
+	pool.Start()
+	future, err := Submit(pool, func(worker *StealingWorker) (int, error) { return sumRange(worker, 0, 10000) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum, err := future.Get(); err != nil || sum != 9999*10000/2 {
+		t.Fatalf("got %v, %v", sum, err)
+	}
+	pool.Shutdown()
+	waitOrFail(t, pool)
+}
+
+// Join runs a child nobody has started on the joining worker, and nothing else, however many tasks wait on its deque
+func TestJoinRunsOnlyItsChild(t *testing.T) {
+	pool := NewPool(1, NewChaseLevDEQueue)
+	pool.Start()
+	var others int32
+	future, err := Submit(pool, func(worker *StealingWorker) (bool, error) {
+		for i := 0; i < 10; i++ {
+			worker.Push(func(*StealingWorker) { atomic.AddInt32(&others, 1) })
+		}
+		child := Fork(worker, func(runner *StealingWorker) (*StealingWorker, error) { return runner, nil })
+		runner, err := Join(worker, child)
+		return runner == worker && atomic.LoadInt32(&others) == 0, err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if onlyChild, err := future.Get(); err != nil || !onlyChild {
+		t.Fatalf("Join ran other tasks or ran the child elsewhere: %v, %v", onlyChild, err)
+	}
+	pool.Shutdown()
+	waitOrFail(t, pool)
+	if others != 10 {
+		t.Fatalf("%v of the other 10 tasks ran", others)
+	}
+}
+
+// A child a thief has started is not run again by Join, which waits for the thief to complete it
+func TestJoinWaitsForStolenChild(t *testing.T) {
+	pool := NewPool(2, NewChaseLevDEQueue)
+	pool.SetIdlePolicy(IdlePark)
+	pool.Start()
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var runs int32
+	future, err := Submit(pool, func(worker *StealingWorker) (int, error) {
+		child := Fork(worker, func(*StealingWorker) (int, error) {
+			atomic.AddInt32(&runs, 1)
+			close(started)
+			<-release
+			return 42, nil
+		})
+		<-started // the child can only start on the other worker, while this one waits here
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			close(release)
+		}()
+		return Join(worker, child)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := future.Get(); err != nil || value != 42 {
+		t.Fatalf("got %v, %v", value, err)
+	}
+	pool.Shutdown()
+	waitOrFail(t, pool)
+	if runs != 1 {
+		t.Fatalf("child ran %v times", runs)
+	}
+}
+
+func TestPanicBecomesPanicError(t *testing.T) {
+	pool := NewPool(2, NewChaseLevDEQueue)
+	pool.Start()
+	future, _ := Submit(pool, func(worker *StealingWorker) (int, error) { panic("boom") })
+	joined, _ := Submit(pool, func(worker *StealingWorker) (int, error) {
+		child := Fork(worker, func(thief *StealingWorker) (int, error) { panic("child boom") })
+		return Join(worker, child)
+	})
+	for _, f := range []*Future[int]{future, joined} {
+		_, err := f.Get()
+		var panicErr *PanicError
+		if !errors.As(err, &panicErr) {
+			t.Fatalf("got %v, want a *PanicError", err)
+		}
+	}
+	// the workers survived the panics
+	if value, err := mustSubmit(t, pool, 7).Get(); err != nil || value != 7 {
+		t.Fatalf("got %v, %v after a panic", value, err)
+	}
+	pool.Shutdown()
+	waitOrFail(t, pool)
+}
+
+// A plain Runnable has no future, so its panic is kept by the pool, which must still run everything else and terminate
+func TestPanickingRunnableIsRecovered(t *testing.T) {
+	for _, idle := range []IdlePolicy{IdleSpin, IdlePark} {
+		pool := NewPool(3, NewChaseLevDEQueue)
+		pool.SetIdlePolicy(idle)
+		tasks, runs := countingTasks(100)
+		pool.Workers()[0].Push(func(worker *StealingWorker) { panic("pushed boom") })
+		for _, task := range tasks[:50] {
+			pool.Workers()[1].Push(task)
+		}
+		pool.Start()
+		if err := pool.Execute(func(worker *StealingWorker) { panic("submitted boom") }); err != nil {
+			t.Fatal(err)
+		}
+		for _, task := range tasks[50:] {
+			if err := pool.Execute(task); err != nil {
+				t.Fatal(err)
+			}
+		}
+		pool.Shutdown()
+		waitOrFail(t, pool)
+		checkRanOnce(t, runs)
+
+		panics := map[interface{}]bool{}
+		for _, panicErr := range pool.Panics() {
+			panics[panicErr.Value] = true
+			if len(panicErr.Stack) == 0 {
+				t.Errorf("panic %v has no stack", panicErr.Value)
+			}
+		}
+		if len(panics) != 2 || !panics["pushed boom"] || !panics["submitted boom"] {
+			t.Errorf("Panics() = %v, want the pushed and the submitted one", pool.Panics())
+		}
+	}
+}
+
+func mustSubmit(t *testing.T, pool *Pool, value int) *Future[int] {
+	t.Helper()
+	future, err := Submit(pool, func(worker *StealingWorker) (int, error) { return value, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return future
+}
+
+func TestSubmitAfterShutdown(t *testing.T) {
+	pool := NewPool(2, NewChaseLevDEQueue)
+	pool.Start()
+	pool.Shutdown()
+	if _, err := Submit(pool, func(worker *StealingWorker) (int, error) { return 0, nil }); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Submit after Shutdown returned %v, want ErrClosed", err)
+	}
+	if err := pool.Execute(func(worker *StealingWorker) {}); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Execute after Shutdown returned %v, want ErrClosed", err)
+	}
+	waitOrFail(t, pool)
+}
+
+// Thieves taking half of a deque while forked tasks are pushed, with idle workers parking, must still run everything and exit
+func TestStealHalfAndParkTerminate(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		pool := NewPool(8, NewChaseLevDEQueue)
+		pool.SetStealAmount(StealHalf)
+		pool.SetIdlePolicy(IdlePark)
+		tasks, runs := countingTasks(200)
+		for _, task := range tasks {
+			pool.Workers()[0].Push(task)
+		}
+		pool.Start()
+		futures := []*Future[int]{}
+		for i := 0; i < 20; i++ {
+			future, err := Submit(pool, func(worker *StealingWorker) (int, error) { return sumRange(worker, 0, 1000) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			futures = append(futures, future)
+		}
+		pool.Shutdown()
+		waitOrFail(t, pool)
+		checkRanOnce(t, runs)
+		for _, future := range futures {
+			if sum, err := future.Get(); err != nil || sum != 999*1000/2 {
+				t.Fatalf("got %v, %v", sum, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package stealing
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+/*
+WorkerStats counts how a StealingWorker spent its run. The counters are only written by the
+worker's own goroutine, so they must only be read once the workers are done.
+*/
+type WorkerStats struct {
+	LocalTasks    int           // tasks popped from its own queue and executed
+	Submitted     int           // tasks taken from the inbox and executed
+	StealAttempts int           // PopTop calls made on a victim's queue
+	Steals        int           // stolen tasks executed
+	Batched       int           // extra tasks moved onto its own deque when stealing half
+	FailedCAS     int           // steal attempts that lost the race for the victim's top
+	EmptyProbes   int           // victims skipped because their queue was empty (or was its own)
+	Yields        int           // runtime.Gosched calls made while looking for work
+	Sleeps        int           // backoff sleeps taken while idle
+	Parks         int           // times the worker parked until woken by new work
+	Busy          time.Duration // time spent executing tasks
+	Idle          time.Duration // time spent in Run outside of tasks
+}
+
+// Add accumulates other into stats
+func (stats *WorkerStats) Add(other WorkerStats) {
+	stats.LocalTasks += other.LocalTasks
+	stats.Submitted += other.Submitted
+	stats.StealAttempts += other.StealAttempts
+	stats.Steals += other.Steals
+	stats.Batched += other.Batched
+	stats.FailedCAS += other.FailedCAS
+	stats.EmptyProbes += other.EmptyProbes
+	stats.Yields += other.Yields
+	stats.Sleeps += other.Sleeps
+	stats.Parks += other.Parks
+	stats.Busy += other.Busy
+	stats.Idle += other.Idle
+}
+
+// WriteStats prints a table with one row per worker followed by the totals
+func WriteStats(w io.Writer, workers []*StealingWorker) {
+	format := "%-8v %8v %9v %9v %8v %8v %10v %8v %8v %8v %6v %12v %12v %6v\n"
+	fmt.Fprintf(w, format, "worker", "local", "submitted", "attempts", "steals", "batched", "failedCAS", "empty", "yields", "sleeps", "parks", "busy", "idle", "busy%")
+	total := WorkerStats{}
+	for _, worker := range workers {
+		writeStatsRow(w, format, worker.ID, worker.Stats)
+		total.Add(worker.Stats)
+	}
+	writeStatsRow(w, format, "total", total)
+}
+
+func writeStatsRow(w io.Writer, format string, name interface{}, stats WorkerStats) {
+	busyPercent := 0.0
+	if stats.Busy+stats.Idle > 0 {
+		busyPercent = 100 * float64(stats.Busy) / float64(stats.Busy+stats.Idle)
+	}
+	fmt.Fprintf(w, format, name, stats.LocalTasks, stats.Submitted, stats.StealAttempts, stats.Steals, stats.Batched, stats.FailedCAS,
+		stats.EmptyProbes, stats.Yields, stats.Sleeps, stats.Parks, stats.Busy.Round(time.Microsecond), stats.Idle.Round(time.Microsecond),
+		fmt.Sprintf("%.1f", busyPercent))
+}
@@ -1,79 +1,143 @@
 package stealing
 
 import (
-	"math/rand"
-	"proj3/utils"
-	"runtime"
-	"sync"
+	"runtime/debug"
 	"sync/atomic"
+	"time"
 )
 
-type StealingWorkerContext struct {
-	TotalCases  int
-	TotalTests  int
-	TotalDeaths int
-	Records     map[string]bool
-	Flag        int32
-	Group       *sync.WaitGroup
-	Args        *utils.Arguments
-	Queues      []DEQueue
-	Workers     []*StealingWorker
-	NumEmptied  int32
-	NumThreads  int32
-}
-
 type StealingWorker struct {
 	LocalQueue DEQueue
 	ID         int
-	Emptied    bool
-	NoMoreTask bool
-	Ctx        *StealingWorkerContext
+	Pool       *Pool
+	Stats      WorkerStats
+	panics     []*PanicError // plain tasks that panicked on this worker, see execute
+	victims    VictimPolicy
 }
 
-func NewStealingWorker(assignedQueue int, ctx interface{},
-	queues []DEQueue, id int) *StealingWorker {
-	newWorker := StealingWorker{LocalQueue: queues[assignedQueue], Ctx: ctx.(*StealingWorkerContext), ID: id}
-	return &newWorker
-}
+/*
+Run executes tasks until the pool is shut down and drained. A worker first works through its
+own deque. Once that is empty it takes submitted tasks from the inbox, and failing that tries
+to steal from the top of another worker's deque. Only the tasks the worker runs push onto its
+deque, so it goes back to its deque after running a task from elsewhere.
 
+A worker exits once the pool has been shut down and no task is outstanding, see
+Pool.terminated. Whenever it finds nothing to do it goes idle according to the pool's
+IdlePolicy.
+*/
 func (worker *StealingWorker) Run() {
-	// Loop runs when there are more tasks to be enqueued to local queue
-	// Or when there are still other threads with work left to steal
-	for !worker.NoMoreTask || worker.Ctx.NumEmptied < worker.Ctx.NumThreads {
+	stats := &worker.Stats
+	start := time.Now()
+	pool := worker.Pool
+	emptied := false
+	failures := 0
+	for {
 		// Still has own tasks to do, so do not steal
-		if !worker.Emptied {
-			task := worker.LocalQueue.PopBottom()
-			if task == nil {
-				// emptied
-				atomic.AddInt32(&worker.Ctx.NumEmptied, 1)
-				worker.Emptied = true
+		if !emptied {
+			if task := worker.LocalQueue.PopBottom(); task != nil {
+				worker.execute(task)
+				stats.LocalTasks++
 			} else {
-				// Execute the task
-				task(worker.Ctx)
-			}
-		} else {
-			// No more work in local queue, try to steal after yielding CPU for any other
-			// thread who hasn't finished
-			runtime.Gosched()
-			victimID := rand.Intn(int(worker.Ctx.NumThreads))
-			if victimID == worker.ID || worker.Ctx.Queues[victimID].IsEmpty() {
-				continue
+				emptied = true
 			}
-			task := worker.Ctx.Queues[victimID].PopTop()
-			if task != nil {
-				task(worker.Ctx)
+			continue
+		}
+
+		// No more work in local queue, take a submitted task if there is one
+		if task := pool.inbox.Take(); task != nil {
+			failures = 0
+			emptied = false
+			worker.execute(task)
+			stats.Submitted++
+			continue
+		}
+
+		// Exit the loop after the pool has been shut down and all the work is done
+		if pool.terminated() {
+			break
+		}
+
+		// Otherwise try to steal, and go idle for a while if there was nothing to steal
+		if task := worker.trySteal(); task != nil {
+			failures = 0
+			emptied = false
+			worker.execute(task)
+			stats.Steals++
+			continue
+		}
+		failures++
+		worker.idle(failures)
+	}
+	stats.Idle = time.Since(start) - stats.Busy
+	// calling Done on waitgroup
+	pool.group.Done()
+}
+
+/*
+trySteal makes one attempt to steal from the victim chosen by the worker's policy. With the
+StealHalf amount, a successful thief also moves up to half of the tasks the victim had onto
+its own deque, from where other idle workers can in turn steal them.
+*/
+func (worker *StealingWorker) trySteal() Runnable {
+	pool := worker.Pool
+	victimID := worker.victims.Victim()
+	victim := pool.queues[victimID]
+	if victimID == worker.ID || victim.IsEmpty() {
+		worker.Stats.EmptyProbes++
+		worker.victims.Observe(victimID, false)
+		return nil
+	}
+	worker.Stats.StealAttempts++
+	available := victim.Size()
+	task, contended := victim.StealTop()
+	if contended {
+		worker.Stats.FailedCAS++
+	}
+	worker.victims.Observe(victimID, task != nil)
+	if task != nil && pool.stealAmount == StealHalf {
+		for taken := 1; taken < available/2; taken++ {
+			extra, _ := victim.StealTop()
+			if extra == nil {
+				break
 			}
+			// the task is still outstanding, it only moves, so push without counting it again
+			worker.LocalQueue.PushBottom(extra)
+			pool.notify()
+			worker.Stats.Batched++
 		}
 	}
-	// Exit the loop after getting signal to exit, and calling Done on waitgroup
-	worker.Ctx.Group.Done()
+	return task
 }
 
 /*
-In our implementation Exit() doesn't do much, because the Workers already know that once
-queue is emptied, it will not be refilled. If we have an implementation where new tasks come into
-the queues concurrently, then we would need this function.
+Push adds a task to the bottom of the worker's own deque. It may be called before the pool
+starts, to distribute work up front, or by a task running on this worker. In the latter case
+other workers keep stealing from the deque, which requires a deque whose PushBottom is safe
+against concurrent thieves, such as ChaseLevDEQueue. A parked worker is woken to steal it.
 */
-func (worker *StealingWorker) Exit() {
-	worker.NoMoreTask = true
+func (worker *StealingWorker) Push(task Runnable) {
+	atomic.AddInt64(&worker.Pool.pending, 1)
+	worker.LocalQueue.PushBottom(task)
+	worker.Pool.notify()
+}
+
+/*
+execute runs a task and bills the time it took to the worker's busy time, including any
+children it ran itself while joining them. The task is no longer outstanding once it
+returns, by which time anything it pushed or submitted has been counted.
+
+A typed task reports a panic through its future, see call, but a plain Runnable has nowhere
+to report it. So a panic is recovered here and kept as a *PanicError, see Pool.Panics, and the
+task still counts as finished: the worker carries on and the pool can still terminate.
+*/
+func (worker *StealingWorker) execute(task Runnable) {
+	start := time.Now()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			worker.panics = append(worker.panics, &PanicError{Value: recovered, Stack: debug.Stack()})
+		}
+		worker.Stats.Busy += time.Since(start)
+		worker.Pool.finish()
+	}()
+	task(worker)
 }
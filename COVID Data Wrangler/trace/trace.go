@@ -0,0 +1,134 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Kind is what a worker was doing during a traced span
+type Kind string
+
+const (
+	Parse   Kind = "parse"   // reading and validating one file
+	Read    Kind = "read"    // only reading one file, where reading and validating are separate
+	Lock    Kind = "lock"    // waiting for the lock guarding the global records
+	Merge   Kind = "merge"   // merging records into the global records
+	Barrier Kind = "barrier" // waiting for the other workers at a BSP barrier
+)
+
+// Event is a single span of work by one worker. FileNum is 0 when the span is not tied to a file
+type Event struct {
+	Worker  int
+	Kind    Kind
+	FileNum int
+	Start   time.Time
+	End     time.Time
+}
+
+/*
+A Recorder collects the spans of one run. Every worker records into its own buffer, so
+recording never makes workers contend with each other, as long as each worker ID is only
+used by one goroutine at a time.
+
+A nil *Recorder is valid and records nothing, so modes can trace unconditionally and
+only pay for it when tracing was requested.
+*/
+type Recorder struct {
+	name    string
+	start   time.Time
+	buffers [][]Event
+}
+
+// NewRecorder returns a recorder for numWorkers workers, named after the mode being traced
+func NewRecorder(name string, numWorkers int) *Recorder {
+	return &Recorder{name: name, start: time.Now(), buffers: make([][]Event, numWorkers)}
+}
+
+// Now returns the current time, or the zero time without reading the clock on a nil recorder
+func (recorder *Recorder) Now() time.Time {
+	if recorder == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+// Record adds a span for worker that started at start and ends now
+func (recorder *Recorder) Record(worker int, kind Kind, fileNum int, start time.Time) {
+	if recorder == nil {
+		return
+	}
+	recorder.buffers[worker] = append(recorder.buffers[worker],
+		Event{Worker: worker, Kind: kind, FileNum: fileNum, Start: start, End: time.Now()})
+}
+
+// Events returns every recorded span, grouped by worker. Only call once the workers are done
+func (recorder *Recorder) Events() []Event {
+	events := []Event{}
+	for _, buffer := range recorder.buffers {
+		events = append(events, buffer...)
+	}
+	return events
+}
+
+// traceEvent is one entry of the Chrome trace_event format, timestamps are in microseconds
+type traceEvent struct {
+	Name      string                 `json:"name"`
+	Category  string                 `json:"cat,omitempty"`
+	Phase     string                 `json:"ph"`
+	Timestamp float64                `json:"ts"`
+	Duration  float64                `json:"dur"` // complete events need one even when it is 0
+	Pid       int                    `json:"pid"`
+	Tid       int                    `json:"tid"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+/*
+WriteJSON writes the recorded spans as Chrome trace_event JSON, which can be opened in
+Perfetto or chrome://tracing. Each worker is shown as its own thread and each span as a
+complete ("X") event categorised by its kind.
+*/
+func (recorder *Recorder) WriteJSON(w io.Writer) error {
+	events := []traceEvent{{Name: "process_name", Phase: "M", Pid: 1,
+		Args: map[string]interface{}{"name": recorder.name}}}
+	for worker := range recorder.buffers {
+		events = append(events, traceEvent{Name: "thread_name", Phase: "M", Pid: 1, Tid: worker,
+			Args: map[string]interface{}{"name": fmt.Sprintf("worker %v", worker)}})
+	}
+	for _, event := range recorder.Events() {
+		name := string(event.Kind)
+		var args map[string]interface{}
+		if event.FileNum != 0 {
+			name = fmt.Sprintf("%v covid_%v", event.Kind, event.FileNum)
+			args = map[string]interface{}{"file": event.FileNum}
+		}
+		events = append(events, traceEvent{
+			Name:      name,
+			Category:  string(event.Kind),
+			Phase:     "X",
+			Timestamp: float64(event.Start.Sub(recorder.start).Nanoseconds()) / 1000,
+			Duration:  float64(event.End.Sub(event.Start).Nanoseconds()) / 1000,
+			Pid:       1,
+			Tid:       event.Worker,
+			Args:      args,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	return encoder.Encode(map[string]interface{}{"traceEvents": events, "displayTimeUnit": "ms"})
+}
+
+// WriteFile writes the trace to path, see WriteJSON
+func (recorder *Recorder) WriteFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := recorder.WriteJSON(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,89 @@
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWriteJSON(t *testing.T) {
+	recorder := NewRecorder("stealing", 2)
+	start := recorder.Now()
+	time.Sleep(2 * time.Millisecond)
+	recorder.Record(0, Parse, 7, start)
+	lockStart := recorder.Now()
+	recorder.Record(1, Lock, 0, lockStart) // may well take no time at all
+	mergeStart := recorder.Now()
+	time.Sleep(time.Millisecond)
+	recorder.Record(1, Merge, 7, mergeStart)
+
+	var buf bytes.Buffer
+	if err := recorder.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []map[string]interface{} `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &trace); err != nil {
+		t.Fatalf("%v in %s", err, buf.Bytes())
+	}
+
+	names := map[float64]bool{}
+	spans := []map[string]interface{}{}
+	for _, event := range trace.TraceEvents {
+		switch event["ph"] {
+		case "M":
+			if event["name"] == "thread_name" {
+				names[event["tid"].(float64)] = true
+			}
+		case "X":
+			spans = append(spans, event)
+		default:
+			t.Errorf("event %v has phase %v", event, event["ph"])
+		}
+	}
+	if !names[0] || !names[1] {
+		t.Errorf("threads named %v, want workers 0 and 1", names)
+	}
+
+	want := []struct {
+		name   string
+		cat    string
+		tid    float64
+		minDur float64
+	}{{"parse covid_7", "parse", 0, 2000}, {"lock", "lock", 1, 0}, {"merge covid_7", "merge", 1, 1000}}
+	if len(spans) != len(want) {
+		t.Fatalf("%v spans, want %v: %v", len(spans), len(want), spans)
+	}
+	ends := map[interface{}]float64{}
+	for i, span := range spans {
+		ts, hasTs := span["ts"].(float64)
+		dur, hasDur := span["dur"].(float64)
+		if span["name"] != want[i].name || span["cat"] != want[i].cat || span["tid"] != want[i].tid {
+			t.Errorf("span %v = %v, want %v on thread %v", i, span, want[i].name, want[i].tid)
+		}
+		if !hasTs || !hasDur || ts < 0 || dur < want[i].minDur {
+			t.Errorf("span %v = %v, want a timestamp and a duration of at least %vµs", i, span, want[i].minDur)
+		}
+		// the spans of a worker follow each other, in microseconds since the recorder started
+		if ts < ends[span["tid"]] {
+			t.Errorf("span %v starts at %v, before the previous span of its worker ended at %v", i, ts, ends[span["tid"]])
+		}
+		ends[span["tid"]] = ts + dur
+	}
+	if file, ok := spans[0]["args"].(map[string]interface{}); !ok || file["file"] != 7.0 {
+		t.Errorf("parse span args = %v, want file 7", spans[0]["args"])
+	}
+}
+
+// A nil recorder is what the modes trace into without -trace, so it must record nothing and not panic
+func TestNilRecorderIsNoOp(t *testing.T) {
+	var recorder *Recorder
+	start := recorder.Now()
+	if !start.IsZero() {
+		t.Errorf("nil recorder read the clock: %v", start)
+	}
+	recorder.Record(3, Parse, 1, start)
+	recorder.Record(0, Merge, 0, start)
+}
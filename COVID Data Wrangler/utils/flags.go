@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseFlags parses the flags in args with fs, allowing them to appear before, between or
+// after the positional arguments, and returns the positional arguments in order
+func ParseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// ParseIntList parses a comma separated list of integers such as "2,4,8"
+func ParseIntList(list string) ([]int, error) {
+	values := []int{}
+	for _, field := range SplitList(list) {
+		value, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q in list %q", field, list)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// SplitList splits a comma separated list, dropping empty entries and surrounding spaces
+func SplitList(list string) []string {
+	fields := []string{}
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+)
+
+// ByteRange is the part of a data file from byte Start up to byte End, made of whole csv lines
+type ByteRange struct {
+	FileNum int
+	Start   int64
+	End     int64
+}
+
+/*
+SplitFile splits a data file into ranges of about maxBytes, cutting only at the end of a csv
+line, so that the ranges can be parsed independently of each other: every range but the last
+ends at the first line end at or after the next multiple of maxBytes. A newline inside a quoted
+field does not end a line. Files of at most maxBytes are returned as a single range, and an
+error if the file cannot be read.
+
+Whether a newline is inside quotes depends on every quote before it, which would take a scan
+of the whole file from the start. Instead the file is cut into one segment per core, which are
+scanned in parallel, see scanSegment. An escaped quote ("") simply closes and reopens the
+quoted field, so a segment only has to count its quotes: a newline is outside quotes if the
+segment started outside quotes and has seen an even number of quotes before it, or started
+inside and has seen an odd number. Every segment keeps the candidate cuts for both cases, and
+a short pass over the segments in order then picks the ones that apply.
+*/
+func SplitFile(fileNum int, maxBytes int64) ([]ByteRange, error) {
+	info, err := os.Stat(FilePath(fileNum))
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if maxBytes <= 0 || size <= maxBytes {
+		return []ByteRange{{FileNum: fileNum, Start: 0, End: size}}, nil
+	}
+
+	numSegments := int64(runtime.GOMAXPROCS(0))
+	if targets := size / maxBytes; numSegments > targets {
+		numSegments = targets
+	}
+	segmentBytes := (size + numSegments - 1) / numSegments
+	segments := make([]segmentScan, numSegments)
+	errs := make([]error, numSegments)
+	var group sync.WaitGroup
+	for i := range segments {
+		group.Add(1)
+		go func(i int) {
+			defer group.Done()
+			start := int64(i) * segmentBytes
+			end := start + segmentBytes
+			if end > size {
+				end = size
+			}
+			segments[i], errs[i] = scanSegment(fileNum, start, end, maxBytes)
+		}(i)
+	}
+	group.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// walk the segments in order, knowing whether each starts inside quotes. Targets whose cut
+	// was not found in their own segment are pending until the first line end after them
+	cuts := []int64{}
+	pending := false
+	inQuotes := 0
+	for _, segment := range segments {
+		if pending && segment.first[inQuotes] > 0 {
+			cuts = append(cuts, segment.first[inQuotes])
+			pending = false
+		}
+		for _, found := range segment.cuts {
+			if found[inQuotes] > 0 {
+				cuts = append(cuts, found[inQuotes])
+			} else {
+				pending = true
+			}
+		}
+		inQuotes ^= segment.quotes
+	}
+
+	ranges := []ByteRange{}
+	start := int64(0)
+	for _, cut := range cuts {
+		// a line longer than maxBytes is the cut of several targets
+		if cut > start && cut < size {
+			ranges = append(ranges, ByteRange{FileNum: fileNum, Start: start, End: cut})
+			start = cut
+		}
+	}
+	return append(ranges, ByteRange{FileNum: fileNum, Start: start, End: size}), nil
+}
+
+/*
+segmentScan is what scanSegment finds in a segment of a file. Index 0 of a pair applies if the
+segment starts outside quotes, index 1 if it starts inside. Offsets are those of the byte after
+a newline, 0 if there is none.
+*/
+type segmentScan struct {
+	quotes int        // number of quotes in the segment, mod 2
+	first  [2]int64   // first line end in the segment
+	cuts   [][2]int64 // for every multiple of maxBytes in the segment, the first line end at or after it
+}
+
+// scanSegment scans the bytes from start up to end of a data file, see segmentScan
+func scanSegment(fileNum int, start int64, end int64, maxBytes int64) (segmentScan, error) {
+	scan := segmentScan{}
+	file, err := os.Open(FilePath(fileNum))
+	if err != nil {
+		return scan, err
+	}
+	defer file.Close()
+
+	// the targets are the multiples of maxBytes in (start, end], waiting[c] is the first one
+	// still without a cut for case c
+	firstTarget := (start/maxBytes + 1) * maxBytes
+	for target := firstTarget; target <= end; target += maxBytes {
+		scan.cuts = append(scan.cuts, [2]int64{})
+	}
+	waiting := [2]int{}
+
+	reader := io.NewSectionReader(file, start, end-start)
+	buffer := make([]byte, 1<<20)
+	offset := start // offset in the file of the first byte in the buffer
+	for {
+		n, err := reader.Read(buffer)
+		block := buffer[:n]
+		for i := 0; i < n; i++ {
+			found := bytes.IndexAny(block[i:], "\"\n")
+			if found < 0 {
+				break
+			}
+			i += found
+			if block[i] == '"' {
+				scan.quotes ^= 1
+				continue
+			}
+			// outside quotes if the segment started outside and has seen an even number of quotes, or the other way round
+			lineEnd := offset + int64(i) + 1
+			c := scan.quotes
+			if scan.first[c] == 0 {
+				scan.first[c] = lineEnd
+			}
+			for ; waiting[c] < len(scan.cuts) && firstTarget+int64(waiting[c])*maxBytes <= lineEnd; waiting[c]++ {
+				scan.cuts[waiting[c]][c] = lineEnd
+			}
+		}
+		offset += int64(n)
+		if err == io.EOF {
+			return scan, nil
+		}
+		if err != nil {
+			return scan, err
+		}
+	}
+}
+
+/*
+ReadRange reads the csv lines of a range of a data file, the header included if the range
+starts the file. It fails if the file cannot be read or no longer holds the whole range, e.g.
+because it was truncated after it was split.
+*/
+func ReadRange(byteRange ByteRange) ([][]string, error) {
+	file, err := os.Open(FilePath(byteRange.FileNum))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data := make([]byte, byteRange.End-byteRange.Start)
+	if _, err := file.ReadAt(data, byteRange.Start); err != nil {
+		return nil, fmt.Errorf("reading bytes %v to %v: %w", byteRange.Start, byteRange.End, err)
+	}
+	return SplitCSV(data)
+}
+
+// ParseRange builds the records for the lines of a range matching the query, see ParseLines
+func ParseRange(args *Arguments, byteRange ByteRange) (map[string][]int, error) {
+	csvLines, err := ReadRange(byteRange)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLines(args, csvLines), nil
+}
@@ -0,0 +1,102 @@
+package utils_test
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"proj3/datatest"
+	"proj3/utils"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// The ranges must cover the file, cut only at line ends outside quotes, and parse to the lines of the whole file
+func TestSplitFileMatchesWholeFile(t *testing.T) {
+	var content strings.Builder
+	content.WriteString("zip,week,note\r\n")
+	for i := 0; i < 3000; i++ {
+		switch i % 7 {
+		case 0:
+			fmt.Fprintf(&content, "%v,%v,\"multi\nline \"\"quoted\"\"\nnote\"\r\n", 60600+i%50, i)
+		case 3:
+			fmt.Fprintf(&content, "%v,%v,\"%v\"\n", 60600+i%50, i, strings.Repeat("long\n", i%40))
+		default:
+			fmt.Fprintf(&content, "%v,%v,plain\n", 60600+i%50, i)
+		}
+	}
+	datatest.WithFiles(t, map[int]string{1: content.String()})
+	whole, err := csv.NewReader(strings.NewReader(content.String())).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	for _, procs := range []int{1, 3, 16} {
+		runtime.GOMAXPROCS(procs)
+		for _, maxBytes := range []int64{7, 100, 1000, 4096, 50000, int64(content.Len())} {
+			ranges, err := utils.SplitFile(1, maxBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := [][]string{}
+			start := int64(0)
+			for _, byteRange := range ranges {
+				if byteRange.Start != start || byteRange.End <= byteRange.Start {
+					t.Fatalf("procs %v, maxBytes %v: range %+v does not follow %v", procs, maxBytes, byteRange, start)
+				}
+				start = byteRange.End
+				rangeLines, err := utils.ReadRange(byteRange)
+				if err != nil {
+					t.Fatalf("procs %v, maxBytes %v: range %+v: %v", procs, maxBytes, byteRange, err)
+				}
+				lines = append(lines, rangeLines...)
+			}
+			if start != int64(content.Len()) {
+				t.Fatalf("procs %v, maxBytes %v: ranges end at %v of %v bytes", procs, maxBytes, start, content.Len())
+			}
+			if !reflect.DeepEqual(lines, whole) {
+				t.Fatalf("procs %v, maxBytes %v: %v ranges parse to %v lines, want the %v of the whole file", procs, maxBytes, len(ranges), len(lines), len(whole))
+			}
+		}
+	}
+}
+
+// A file that is missing, or shorter than the ranges it was split into, must fail instead of reading as no or fewer lines
+func TestReadingMissingOrTruncatedFilesFails(t *testing.T) {
+	content := datatest.Covid(1, 200, 1)[1]
+	datatest.WithFiles(t, map[int]string{1: content})
+
+	if _, err := utils.SplitFile(2, 1000); err == nil {
+		t.Error("SplitFile of a missing file succeeded")
+	}
+	if _, err := utils.ReadBytes(2); err == nil {
+		t.Error("ReadBytes of a missing file succeeded")
+	}
+	if _, err := utils.ReadFile(2); err == nil {
+		t.Error("ReadFile of a missing file succeeded")
+	}
+	if _, err := utils.ReadRange(utils.ByteRange{FileNum: 2, Start: 0, End: 10}); err == nil {
+		t.Error("ReadRange of a missing file succeeded")
+	}
+
+	ranges, err := utils.SplitFile(1, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) < 3 {
+		t.Fatalf("%v bytes split into %v ranges, want several", len(content), len(ranges))
+	}
+	// the file loses the end of its second to last range, as if it was cut short after being split
+	cut := ranges[len(ranges)-2].End - 10
+	if err := os.Truncate(utils.FilePath(1), cut); err != nil {
+		t.Fatal(err)
+	}
+	for _, byteRange := range ranges {
+		_, err := utils.ReadRange(byteRange)
+		if truncated := byteRange.End > cut; truncated != (err != nil) {
+			t.Errorf("range %+v of a file cut at %v bytes: error %v", byteRange, cut, err)
+		}
+	}
+}
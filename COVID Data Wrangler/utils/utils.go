@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"bytes"
 	"encoding/csv"
 	"fmt"
 	"os"
@@ -25,6 +26,24 @@ type Arguments struct {
 	Year    int
 }
 
+// Result holds the final tallies for a query together with the deduplicated records
+// they were computed from, keyed the same way as the records returned by ParseFile
+type Result struct {
+	TotalCases  int
+	TotalTests  int
+	TotalDeaths int
+	Records     map[string][]int
+}
+
+func NewResult() *Result {
+	return &Result{Records: make(map[string][]int)}
+}
+
+// String formats the tallies the way the program prints them: cases,tests,deaths
+func (result *Result) String() string {
+	return fmt.Sprintf("%v,%v,%v", result.TotalCases, result.TotalTests, result.TotalDeaths)
+}
+
 func ValidateLine(args *Arguments, line []string) bool {
 	zipcode := args.Zipcode
 	month := args.Month
@@ -54,40 +73,88 @@ func ValidateLine(args *Arguments, line []string) bool {
 	return true
 }
 
+// ParseFile builds the records of a data file. A file that cannot be read or parsed has none
 func ParseFile(args *Arguments, fileNum int) map[string][]int {
+	csvLines, _ := ReadFile(fileNum)
+	return ParseLines(args, csvLines)
+}
 
-	// start counter and set up file path
-	fileRecords := make(map[string][]int)
-	filePath := fmt.Sprintf("../data/covid_%v.csv", fileNum)
+// ReadFile reads every csv line of a data file, the header included
+func ReadFile(fileNum int) ([][]string, error) {
+	data, err := ReadBytes(fileNum)
+	if err != nil {
+		return nil, err
+	}
+	return SplitCSV(data)
+}
+
+// ReadBytes reads the raw contents of a data file
+func ReadBytes(fileNum int) ([]byte, error) {
+	return os.ReadFile(FilePath(fileNum))
+}
+
+// SplitCSV splits the raw contents of a data file into its csv lines
+func SplitCSV(data []byte) ([][]string, error) {
+	return csv.NewReader(bytes.NewReader(data)).ReadAll()
+}
+
+// ParseLines builds the records for the lines matching the query, skipping the header and invalid lines
+func ParseLines(args *Arguments, csvLines [][]string) map[string][]int {
 
-	// read the csv file, skip the header
-	csvFile, _ := os.Open(filePath)
-	defer csvFile.Close()
-	csvLines, _ := csv.NewReader(csvFile).ReadAll()
+	// start counter
+	fileRecords := make(map[string][]int)
 	for _, line := range csvLines {
 
 		if !ValidateLine(args, line) {
 			continue
 		}
 
+		key := fmt.Sprintf("zipcode:%v,time:%v", line[ZipcodeCol], line[WeekStart])
+		if _, contains := fileRecords[key]; contains {
+			continue
+		} // skip duplicate within the file, so every record holds exactly one entry
 		cases, _ := strconv.Atoi(line[CasesWeek])
 		tests, _ := strconv.Atoi(line[TestsWeek])
 		deaths, _ := strconv.Atoi(line[DeathsWeek])
-		key := fmt.Sprintf("zipcode:%v,time:%v", line[ZipcodeCol], line[WeekStart])
-		fileRecords[key] = append(fileRecords[key], cases)
-		fileRecords[key] = append(fileRecords[key], tests)
-		fileRecords[key] = append(fileRecords[key], deaths)
+		fileRecords[key] = []int{cases, tests, deaths}
 	}
 
 	return fileRecords
 }
 
-func UpdateGlobal(localRecord map[string][]int, globalRecord map[string]bool, totalCases *int, totalTests *int, totalDeaths *int) {
+/*
+MergeRecords adds the records of from that are not in into yet. When both hold a key the
+record already in into wins, so merging the records of consecutive pieces of work in order
+gives the same result as processing them in one go.
+*/
+func MergeRecords(into map[string][]int, from map[string][]int) {
+	for key, val := range from {
+		if _, contains := into[key]; contains {
+			continue
+		} // skip duplicate
+		into[key] = val
+	}
+}
+
+func FilePath(fileNum int) string {
+	return fmt.Sprintf("../data/covid_%v.csv", fileNum)
+}
+
+// FileSize returns the size of a data file in bytes, or 0 if it cannot be read
+func FileSize(fileNum int) int64 {
+	info, err := os.Stat(FilePath(fileNum))
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func UpdateGlobal(localRecord map[string][]int, globalRecord map[string][]int, totalCases *int, totalTests *int, totalDeaths *int) {
 	for key, val := range localRecord {
 		if _, contains := globalRecord[key]; contains {
 			continue
 		} // skip duplicate
-		globalRecord[key] = true // add the record
+		globalRecord[key] = val // add the record
 		// add to the tallies
 		*totalCases += val[0]
 		*totalTests += val[1]
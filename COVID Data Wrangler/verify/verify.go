@@ -0,0 +1,239 @@
+package verify
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"proj3/modes"
+	"proj3/utils"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const usage = "Usage:	go run proj3/covid verify [flags] size [zipcode month year]\n" +
+	"	size = the number of files to be processed\n" +
+	"	zipcode month year = the query to check, if omitted -samples queries are drawn at random from the data\n" +
+	"	flags:\n"
+
+/*
+A Divergence is a single record on which a mode disagrees with the reference result.
+Want is nil when the mode produced a record the reference does not have, and Got is nil
+when the mode lost a record the reference has.
+*/
+type Divergence struct {
+	Key  string
+	Want []int
+	Got  []int
+}
+
+func (d Divergence) String() string {
+	switch {
+	case d.Got == nil:
+		return fmt.Sprintf("missing %v (want %v)", d.Key, d.Want)
+	case d.Want == nil:
+		return fmt.Sprintf("extra   %v (got %v)", d.Key, d.Got)
+	default:
+		return fmt.Sprintf("differs %v (want %v, got %v)", d.Key, d.Want, d.Got)
+	}
+}
+
+// Diff compares two deduplicated record sets and returns every diverging key, sorted by key
+func Diff(want map[string][]int, got map[string][]int) []Divergence {
+	divergences := []Divergence{}
+	for key, wantVal := range want {
+		gotVal, contains := got[key]
+		if !contains {
+			divergences = append(divergences, Divergence{Key: key, Want: wantVal})
+		} else if !reflect.DeepEqual(wantVal, gotVal) {
+			divergences = append(divergences, Divergence{Key: key, Want: wantVal, Got: gotVal})
+		}
+	}
+	for key, gotVal := range got {
+		if _, contains := want[key]; !contains {
+			divergences = append(divergences, Divergence{Key: key, Got: gotVal})
+		}
+	}
+	sort.Slice(divergences, func(i, j int) bool { return divergences[i].Key < divergences[j].Key })
+	return divergences
+}
+
+/*
+SampleQueries draws up to n distinct queries from the zipcodes and weeks present in the
+first data file, so that sampled queries actually hit records instead of mostly returning 0,0,0
+*/
+func SampleQueries(n int, seed int64) ([]utils.Arguments, error) {
+	csvFile, err := os.Open(utils.FilePath(1))
+	if err != nil {
+		return nil, err
+	}
+	defer csvFile.Close()
+	csvLines, err := csv.NewReader(csvFile).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[utils.Arguments]bool)
+	candidates := []utils.Arguments{}
+	for _, line := range csvLines {
+		if len(line) <= utils.WeekStart {
+			continue
+		}
+		date := strings.Split(line[utils.WeekStart], "/")
+		if len(date) != 3 {
+			continue // header or malformed date
+		}
+		month, _ := strconv.Atoi(date[0])
+		year, _ := strconv.Atoi(date[2])
+		query := utils.Arguments{Zipcode: line[utils.ZipcodeCol], Month: month, Year: year}
+		if !seen[query] {
+			seen[query] = true
+			candidates = append(candidates, query)
+		}
+	}
+
+	// sort first so that the same seed always yields the same queries
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Zipcode != b.Zipcode {
+			return a.Zipcode < b.Zipcode
+		}
+		if a.Year != b.Year {
+			return a.Year < b.Year
+		}
+		return a.Month < b.Month
+	})
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	if n < len(candidates) {
+		candidates = candidates[:n]
+	}
+	return candidates, nil
+}
+
+/*
+Main runs the verify command with the arguments following "verify" on the command line and
+returns the process exit code: 0 if every mode agreed with the sequential reference, 1 if any
+diverged, and 2 on usage errors.
+
+Every selected mode is run at every selected thread count (skipping counts below the mode's
+minimum) for every query, -rounds times each since scheduler bugs tend to be intermittent.
+The full deduplicated record set of each run is diffed against the sequential result.
+*/
+func Main(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	modeList := fs.String("modes", "", "comma separated modes to check (default: every registered parallel mode)")
+	threadList := fs.String("threads", "1,2,3,4,5,6,8,12", "comma separated thread counts to run each mode with")
+	samples := fs.Int("samples", 5, "number of random queries to check when no query is given")
+	seed := fs.Int64("seed", time.Now().UnixNano(), "seed for sampling queries")
+	rounds := fs.Int("rounds", 1, "number of times to repeat each mode and thread count")
+	maxKeys := fs.Int("max-keys", 10, "maximum number of diverging keys to print per run")
+	opts := &modes.Options{}
+	opts.RegisterFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+
+	positional, err := utils.ParseFlags(fs, args)
+	if err != nil {
+		return 2
+	}
+	if err := opts.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(positional) != 1 && len(positional) != 4 {
+		fs.Usage()
+		return 2
+	}
+	size, err := strconv.Atoi(positional[0])
+	if err != nil || size < 1 {
+		fs.Usage()
+		return 2
+	}
+	threads, err := utils.ParseIntList(*threadList)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// Pick the modes under test, sequential is the reference so it is left out by default
+	selected := []modes.Mode{}
+	if *modeList == "" {
+		for _, mode := range modes.Modes() {
+			if mode.Name != "sequential" {
+				selected = append(selected, mode)
+			}
+		}
+	} else {
+		for _, name := range utils.SplitList(*modeList) {
+			mode, ok := modes.Lookup(name)
+			if !ok {
+				fmt.Fprintf(os.Stderr, "unknown mode %q\n", name)
+				return 2
+			}
+			selected = append(selected, mode)
+		}
+	}
+
+	// Build the queries, either the one given or a random sample
+	var queries []utils.Arguments
+	if len(positional) == 4 {
+		month, _ := strconv.Atoi(positional[2])
+		year, _ := strconv.Atoi(positional[3])
+		queries = []utils.Arguments{{Zipcode: positional[1], Month: month, Year: year}}
+	} else {
+		queries, err = SampleQueries(*samples, *seed)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sampling queries: %v\n", err)
+			return 2
+		}
+		fmt.Printf("sampled %v queries with seed %v\n", len(queries), *seed)
+	}
+
+	failures := 0
+	runs := 0
+	for _, query := range queries {
+		query := query
+		reference := modes.RunSequential(&query, size)
+		fmt.Printf("query %v %v/%v: reference %v (%v records)\n",
+			query.Zipcode, query.Month, query.Year, reference, len(reference.Records))
+
+		for _, mode := range selected {
+			for _, numThreads := range threads {
+				if numThreads < mode.MinThreads {
+					continue
+				}
+				for round := 0; round < *rounds; round++ {
+					runs++
+					result := mode.Run(&query, size, numThreads, opts)
+					divergences := Diff(reference.Records, result.Records)
+					if len(divergences) == 0 && result.String() == reference.String() {
+						continue
+					}
+					failures++
+					fmt.Printf("  FAIL %v threads=%v round=%v: got %v, %v diverging keys\n",
+						mode.Name, numThreads, round, result, len(divergences))
+					for i, divergence := range divergences {
+						if i == *maxKeys {
+							fmt.Printf("    ... %v more\n", len(divergences)-*maxKeys)
+							break
+						}
+						fmt.Printf("    %v\n", divergence)
+					}
+				}
+			}
+		}
+	}
+
+	fmt.Printf("%v runs, %v diverged\n", runs, failures)
+	if failures > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,67 @@
+package verify
+
+import (
+	"proj3/datatest"
+	"proj3/utils"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := map[string][]int{"zipcode:1": {1, 2, 3}, "zipcode:2": {4, 5, 6}}
+	for _, test := range []struct {
+		name      string
+		want, got map[string][]int
+		diff      []Divergence
+	}{
+		{"equal", a, map[string][]int{"zipcode:2": {4, 5, 6}, "zipcode:1": {1, 2, 3}}, []Divergence{}},
+		{"both empty", map[string][]int{}, map[string][]int{}, []Divergence{}},
+		{"missing key", a, map[string][]int{"zipcode:1": {1, 2, 3}},
+			[]Divergence{{Key: "zipcode:2", Want: []int{4, 5, 6}}}},
+		{"extra key", a, map[string][]int{"zipcode:0": {7, 8, 9}, "zipcode:1": {1, 2, 3}, "zipcode:2": {4, 5, 6}},
+			[]Divergence{{Key: "zipcode:0", Got: []int{7, 8, 9}}}},
+		{"differing value", a, map[string][]int{"zipcode:1": {1, 2, 3}, "zipcode:2": {4, 0, 6}},
+			[]Divergence{{Key: "zipcode:2", Want: []int{4, 5, 6}, Got: []int{4, 0, 6}}}},
+		{"all at once, sorted by key", a, map[string][]int{"zipcode:1": {1, 2}, "zipcode:3": {0, 0, 0}},
+			[]Divergence{{Key: "zipcode:1", Want: []int{1, 2, 3}, Got: []int{1, 2}}, {Key: "zipcode:2", Want: []int{4, 5, 6}}, {Key: "zipcode:3", Got: []int{0, 0, 0}}}},
+	} {
+		if diff := Diff(test.want, test.got); !reflect.DeepEqual(diff, test.diff) {
+			t.Errorf("%v: Diff = %v, want %v", test.name, diff, test.diff)
+		}
+	}
+}
+
+// The same seed must draw the same queries, so that a failing sample can be reproduced
+func TestSampleQueriesIsDeterministic(t *testing.T) {
+	datatest.WithFiles(t, datatest.Covid(1, 300, 1))
+	first, err := SampleQueries(8, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 8 {
+		t.Fatalf("%v queries, want 8", len(first))
+	}
+	for i := 0; i < 3; i++ {
+		again, err := SampleQueries(8, 42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(again, first) {
+			t.Fatalf("seed 42 drew %v, then %v", first, again)
+		}
+	}
+	seen := make(map[utils.Arguments]bool)
+	for _, query := range first {
+		if seen[query] {
+			t.Errorf("query %+v drawn twice", query)
+		}
+		seen[query] = true
+	}
+	other, err := SampleQueries(8, 43)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(other, first) {
+		t.Errorf("seeds 42 and 43 drew the same queries %v", first)
+	}
+}